@@ -0,0 +1,155 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ServerSideApply applies obj with a server-side apply patch owned by fieldManager.
+// When force is true, conflicting fields owned by other managers are taken over.
+// obj is updated with the state returned by the API server.
+func ServerSideApply(ctx context.Context, c client.Client, obj *unstructured.Unstructured, fieldManager string, force bool) error {
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return fmt.Errorf("object %s must have an apiVersion and a kind to be applied", obj.GetName())
+	}
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if force {
+		opts = append(opts, client.ForceOwnership)
+	}
+	// managed fields are rejected in apply patches
+	obj.SetManagedFields(nil)
+	err := c.Patch(ctx, obj, client.Apply, opts...)
+	// make sure the object can still be identified if the response did not carry its type
+	obj.SetGroupVersionKind(gvk)
+	return err
+}
+
+// Apply creates obj when it does not exist yet, or merge-patches the existing object with the fields set in obj.
+// Fields missing from obj are left untouched on the existing object.
+// The patch carries the resourceVersion of the existing object, so it fails on concurrent modifications.
+// obj is updated with the state returned by the API server.
+func Apply(ctx context.Context, c client.Client, obj *unstructured.Unstructured) (controllerutil.OperationResult, error) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if apierrors.IsNotFound(err) {
+		if err := c.Create(ctx, obj); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		return controllerutil.OperationResultCreated, nil
+	}
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	patch, err := json.Marshal(obj.Object)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	unchanged, err := mergePatchIsNoop(existing, patch)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if unchanged {
+		existing.DeepCopyInto(obj)
+		return controllerutil.OperationResultNone, nil
+	}
+	err = c.Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch))
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	return controllerutil.OperationResultUpdated, nil
+}
+
+// mergePatchIsNoop tells whether applying patch to obj leaves it unchanged.
+func mergePatchIsNoop(obj *unstructured.Unstructured, patch []byte) (bool, error) {
+	original, err := json.Marshal(obj.Object)
+	if err != nil {
+		return false, err
+	}
+	patched, err := jsonpatch.MergePatch(original, patch)
+	if err != nil {
+		return false, err
+	}
+	return jsonpatch.Equal(original, patched), nil
+}
+
+// Prune deletes the objects matching selector that are not part of desired, identified by their group, kind,
+// namespace and name.
+// The kinds of the desired objects are considered, along with the optional extra kinds, allowing to prune kinds
+// no longer present in desired.
+// Namespaced desired objects must define their namespace, see SetDefaultNamespace, otherwise nothing is deleted.
+// Deletion errors, like the ones returned by a read-only client, are returned after all deletions were attempted.
+func Prune(ctx context.Context, c client.Client, desired []*unstructured.Unstructured, selector labels.Selector, kinds ...schema.GroupVersionKind) error {
+	keep := map[objectIdentity]struct{}{}
+	listed := map[schema.GroupKind]struct{}{}
+	gvks := []schema.GroupVersionKind{}
+	for _, o := range desired {
+		if o.GetNamespace() == "" {
+			namespaced, err := c.IsObjectNamespaced(o)
+			if err != nil {
+				return fmt.Errorf("unable to find the scope of desired %s %s: %w", o.GetKind(), o.GetName(), err)
+			}
+			if namespaced {
+				return fmt.Errorf("desired %s %s is namespaced but has no namespace", o.GetKind(), o.GetName())
+			}
+		}
+		keep[identityOf(o)] = struct{}{}
+		kinds = append(kinds, o.GroupVersionKind())
+	}
+	for _, gvk := range kinds {
+		if _, ok := listed[gvk.GroupKind()]; !ok {
+			listed[gvk.GroupKind()] = struct{}{}
+			gvks = append(gvks, gvk)
+		}
+	}
+	errs := []error{}
+	for _, gvk := range gvks {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return err
+		}
+		for i := range list.Items {
+			o := &list.Items[i]
+			if _, ok := keep[identityOf(o)]; ok {
+				continue
+			}
+			if err := c.Delete(ctx, o); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("unable to prune %s %s/%s: %w", gvk.Kind, o.GetNamespace(), o.GetName(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyManifests parses the objects defined in r and applies them, in the SortForApply order, creating or
+// patching each of them like Apply.
+// All objects are attempted, and the errors are returned together, each one naming the failing object.
+func ApplyManifests(ctx context.Context, c client.Client, r io.Reader) error {
+	objects, err := ParseUnstructured(r)
+	if err != nil {
+		return err
+	}
+	SortForApply(objects)
+	errs := []error{}
+	for _, o := range objects {
+		if _, err := Apply(ctx, c, o); err != nil {
+			errs = append(errs, fmt.Errorf("unable to apply %s %s: %w", o.GetKind(), client.ObjectKeyFromObject(o), err))
+		}
+	}
+	return errors.Join(errs...)
+}
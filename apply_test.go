@@ -0,0 +1,220 @@
+package k8s_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+type recordedPatch struct {
+	patchType types.PatchType
+	options   client.PatchOptions
+	object    *unstructured.Unstructured
+}
+
+func newPatchRecordingClient(patches *[]recordedPatch) client.Client {
+	return fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			*patches = append(*patches, recordedPatch{
+				patchType: patch.Type(),
+				options:   *(&client.PatchOptions{}).ApplyOptions(opts),
+				object:    obj.(*unstructured.Unstructured).DeepCopy(),
+			})
+			return nil
+		},
+	}).Build()
+}
+
+func TestServerSideApply(t *testing.T) {
+	t.Run("the object is applied with the field manager", func(t *testing.T) {
+		patches := []recordedPatch{}
+		obj := newUnstructured("v1", "ConfigMap", "default", "config")
+		require.NoError(t, k8s.ServerSideApply(context.Background(), newPatchRecordingClient(&patches), obj, "my-controller", false))
+
+		require.Len(t, patches, 1)
+		assert.Equal(t, types.ApplyPatchType, patches[0].patchType)
+		assert.Equal(t, "my-controller", patches[0].options.FieldManager)
+		assert.Nil(t, patches[0].options.Force)
+		assert.Equal(t, "ConfigMap", patches[0].object.GetKind())
+		assert.Equal(t, "v1", obj.GetAPIVersion())
+	})
+
+	t.Run("ownership can be forced", func(t *testing.T) {
+		patches := []recordedPatch{}
+		obj := newUnstructured("v1", "ConfigMap", "default", "config")
+		require.NoError(t, k8s.ServerSideApply(context.Background(), newPatchRecordingClient(&patches), obj, "my-controller", true))
+
+		require.Len(t, patches, 1)
+		require.NotNil(t, patches[0].options.Force)
+		assert.True(t, *patches[0].options.Force)
+	})
+
+	t.Run("objects without kind are rejected", func(t *testing.T) {
+		patches := []recordedPatch{}
+		obj := newUnstructured("", "", "default", "config")
+		assert.Error(t, k8s.ServerSideApply(context.Background(), newPatchRecordingClient(&patches), obj, "my-controller", true))
+		assert.Empty(t, patches)
+	})
+}
+
+func TestApply(t *testing.T) {
+	t.Run("missing objects are created", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		obj := newUnstructured("v1", "ConfigMap", "default", "config")
+		require.NoError(t, unstructured.SetNestedField(obj.Object, "value", "data", "key"))
+
+		result, err := k8s.Apply(context.Background(), c, obj)
+		require.NoError(t, err)
+		assert.Equal(t, controllerutil.OperationResultCreated, result)
+
+		cm := &v1.ConfigMap{}
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "config"}, cm))
+		assert.Equal(t, map[string]string{"key": "value"}, cm.Data)
+	})
+
+	t.Run("existing objects are patched", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithObjects(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default", Labels: map[string]string{"owner": "someone-else"}},
+			Data:       map[string]string{"key": "old", "other": "kept"},
+		}).Build()
+		obj := newUnstructured("v1", "ConfigMap", "default", "config")
+		obj.SetResourceVersion("1")
+		require.NoError(t, unstructured.SetNestedField(obj.Object, "new", "data", "key"))
+
+		result, err := k8s.Apply(context.Background(), c, obj)
+		require.NoError(t, err)
+		assert.Equal(t, controllerutil.OperationResultUpdated, result)
+
+		cm := &v1.ConfigMap{}
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "config"}, cm))
+		assert.Equal(t, map[string]string{"key": "new", "other": "kept"}, cm.Data)
+		assert.Equal(t, map[string]string{"owner": "someone-else"}, cm.Labels)
+		assert.Equal(t, cm.ResourceVersion, obj.GetResourceVersion())
+
+		t.Run("applying the same object again changes nothing", func(t *testing.T) {
+			obj := newUnstructured("v1", "ConfigMap", "default", "config")
+			require.NoError(t, unstructured.SetNestedField(obj.Object, "new", "data", "key"))
+			result, err := k8s.Apply(context.Background(), c, obj)
+			require.NoError(t, err)
+			assert.Equal(t, controllerutil.OperationResultNone, result)
+		})
+	})
+}
+
+func TestPrune(t *testing.T) {
+	managed := map[string]string{"app.kubernetes.io/managed-by": "gitops"}
+	newClient := func() client.Client {
+		mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, meta.RESTScopeNamespace)
+		return fake.NewClientBuilder().WithRESTMapper(mapper).WithObjects(
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "desired", Namespace: "default", Labels: managed}},
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "stale", Namespace: "default", Labels: managed}},
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "default"}},
+			&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "stale", Namespace: "default", Labels: managed}},
+		).Build()
+	}
+	desired := []*unstructured.Unstructured{newUnstructured("v1", "ConfigMap", "default", "desired")}
+	exists := func(t *testing.T, c client.Client, obj client.Object, name string) bool {
+		t.Helper()
+		err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, obj)
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		require.NoError(t, err)
+		return true
+	}
+
+	t.Run("stale objects are deleted", func(t *testing.T) {
+		c := newClient()
+		require.NoError(t, k8s.Prune(context.Background(), c, desired, labels.SelectorFromSet(managed)))
+		assert.True(t, exists(t, c, &v1.ConfigMap{}, "desired"))
+		assert.False(t, exists(t, c, &v1.ConfigMap{}, "stale"))
+		assert.True(t, exists(t, c, &v1.ConfigMap{}, "unmanaged"))
+		assert.True(t, exists(t, c, &v1.Secret{}, "stale"), "kinds that are not desired nor requested are not pruned")
+	})
+
+	t.Run("extra kinds are pruned", func(t *testing.T) {
+		c := newClient()
+		require.NoError(t, k8s.Prune(context.Background(), c, desired, labels.SelectorFromSet(managed), schema.GroupVersionKind{Version: "v1", Kind: "Secret"}))
+		assert.True(t, exists(t, c, &v1.ConfigMap{}, "desired"))
+		assert.False(t, exists(t, c, &v1.Secret{}, "stale"))
+	})
+
+	t.Run("namespaced desired objects without namespace are rejected", func(t *testing.T) {
+		c := newClient()
+		withoutNamespace := []*unstructured.Unstructured{newUnstructured("v1", "ConfigMap", "", "desired")}
+		err := k8s.Prune(context.Background(), c, withoutNamespace, labels.SelectorFromSet(managed))
+		assert.EqualError(t, err, "desired ConfigMap desired is namespaced but has no namespace")
+		assert.True(t, exists(t, c, &v1.ConfigMap{}, "desired"))
+		assert.True(t, exists(t, c, &v1.ConfigMap{}, "stale"))
+	})
+
+	t.Run("read-only errors are returned", func(t *testing.T) {
+		c := k8s.ReadOnlyClient(newClient())
+		err := k8s.Prune(context.Background(), c, desired, labels.SelectorFromSet(managed))
+		assert.ErrorContains(t, err, "Delete not allowed in read-only mode")
+		assert.True(t, exists(t, c, &v1.ConfigMap{}, "stale"))
+	})
+}
+
+const namespaceBundle = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: my-namespace
+data:
+  key: value
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: my-namespace
+`
+
+func TestApplyManifests(t *testing.T) {
+	t.Run("objects are applied in order", func(t *testing.T) {
+		created := []string{}
+		c := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				created = append(created, obj.GetObjectKind().GroupVersionKind().Kind)
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		require.NoError(t, k8s.ApplyManifests(context.Background(), c, strings.NewReader(namespaceBundle)))
+		assert.Equal(t, []string{"Namespace", "ConfigMap"}, created)
+
+		cm := &v1.ConfigMap{}
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "my-namespace", Name: "config"}, cm))
+		assert.Equal(t, map[string]string{"key": "value"}, cm.Data)
+
+		require.NoError(t, k8s.ApplyManifests(context.Background(), c, strings.NewReader(namespaceBundle)), "applying twice must succeed")
+	})
+
+	t.Run("errors name the failing objects", func(t *testing.T) {
+		err := k8s.ApplyManifests(context.Background(), k8s.ReadOnlyClient(fake.NewClientBuilder().Build()), strings.NewReader(namespaceBundle))
+		assert.ErrorContains(t, err, "unable to apply Namespace /my-namespace: Create not allowed in read-only mode")
+		assert.ErrorContains(t, err, "unable to apply ConfigMap my-namespace/config: Create not allowed in read-only mode")
+	})
+
+	t.Run("parsing errors are returned", func(t *testing.T) {
+		assert.Error(t, k8s.ApplyManifests(context.Background(), fake.NewClientBuilder().Build(), strings.NewReader("kind: [")))
+	})
+}
@@ -1,33 +1,65 @@
 package k8s
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 
 	system "github.com/adevinta/go-system-toolkit"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/transport"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
+// KubeConfigPath returns the path of the kubeconfig file to use, or an empty string when none exists.
+// The first existing file is picked from, in order, configPath, the entries of $KUBECONFIG and $HOME/.kube/config.
+// Like kubectl, $KUBECONFIG can hold a list of paths separated by the OS path list separator.
 func KubeConfigPath(configPath string) string {
-	for _, dflt := range []string{os.Getenv("KUBECONFIG"), filepath.Join(os.Getenv("HOME"), ".kube", "config")} {
-		if configPath == "" {
-			configPath = dflt
+	candidates := append([]string{configPath}, filepath.SplitList(os.Getenv("KUBECONFIG"))...)
+	candidates = append(candidates, filepath.Join(os.Getenv("HOME"), ".kube", "config"))
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if _, err := system.DefaultFileSystem.Stat(candidate); err == nil {
+			return filepath.Clean(candidate)
 		}
 	}
-	_, err := system.DefaultFileSystem.Stat(configPath)
-	if err != nil {
-		return ""
-	}
-	return filepath.Clean(configPath)
+	return ""
 }
 
+// ClientConfigBuilder builds Kubernetes client configurations from kubeconfig files and overrides.
+// The With methods return a new builder and never modify the one they are called on.
+// The loading rules and overrides are pointers shared between plain copies of a builder: use Clone before
+// modifying those fields directly.
 type ClientConfigBuilder struct {
 	ClientConfigLoadingRules *clientcmd.ClientConfigLoadingRules
 	ConfigOverrides          *clientcmd.ConfigOverrides
 	DefaultServerURL         string
 	tokenFile                string
+	bearerToken              string
+	contentType              string
+	acceptContentTypes       string
+	inCluster                bool
+	kubeConfig               []byte
+	defaultNamespace         string
+	tlsServerName            string
+	rateLimiter              flowcontrol.RateLimiter
+	transportWrappers        []transport.WrapperFunc
 }
 
 // NewClientConfigBuilder allows the creation of a flexible Kubernetes client configuration
@@ -39,14 +71,48 @@ func NewClientConfigBuilder() ClientConfigBuilder {
 	}
 }
 
-// WithServerURL forces the Kubernetes server URL regardless of the kubeconfig content
+// Clone returns a copy of the builder whose loading rules and overrides are independent from the original ones.
+func (b ClientConfigBuilder) Clone() ClientConfigBuilder {
+	if b.ClientConfigLoadingRules != nil {
+		rules := *b.ClientConfigLoadingRules
+		rules.Precedence = append([]string(nil), rules.Precedence...)
+		if rules.MigrationRules != nil {
+			rules.MigrationRules = make(map[string]string, len(b.ClientConfigLoadingRules.MigrationRules))
+			for k, v := range b.ClientConfigLoadingRules.MigrationRules {
+				rules.MigrationRules[k] = v
+			}
+		}
+		b.ClientConfigLoadingRules = &rules
+	}
+	if b.ConfigOverrides != nil {
+		overrides := *b.ConfigOverrides
+		b.ConfigOverrides.AuthInfo.DeepCopyInto(&overrides.AuthInfo)
+		b.ConfigOverrides.ClusterDefaults.DeepCopyInto(&overrides.ClusterDefaults)
+		b.ConfigOverrides.ClusterInfo.DeepCopyInto(&overrides.ClusterInfo)
+		b.ConfigOverrides.Context.DeepCopyInto(&overrides.Context)
+		b.ConfigOverrides = &overrides
+	}
+	return b
+}
+
+// WithTokenFile defines the name of a file, next to the kubeconfig, holding the token to use
+// when the kubeconfig does not provide any authentication.
+// Build fails when the file can not be read, or when the kubeconfig is not loaded from a file.
 func (b ClientConfigBuilder) WithTokenFile(token string) ClientConfigBuilder {
 	b.tokenFile = token
 	return b
 }
 
+// WithBearerToken forces the token used to authenticate against the Kubernetes API.
+// When set, no token file is looked up.
+func (b ClientConfigBuilder) WithBearerToken(token string) ClientConfigBuilder {
+	b.bearerToken = token
+	return b
+}
+
 // WithServerURL forces the Kubernetes server URL regardless of the kubeconfig content
 func (b ClientConfigBuilder) WithServerURL(url string) ClientConfigBuilder {
+	b = b.Clone()
 	b.ConfigOverrides.ClusterInfo.Server = url
 	return b
 }
@@ -62,20 +128,53 @@ func (b ClientConfigBuilder) WithDefaultServerURL(url string) ClientConfigBuilde
 // If the filepath is empty or does not exist, the client will fallback to the default kubeconfig paths
 // pointed by the ${KUBECONFIG} environment variable and ${HOME}/.kube/config
 func (b ClientConfigBuilder) WithKubeConfigPath(path string) ClientConfigBuilder {
+	b = b.Clone()
 	b.ClientConfigLoadingRules.ExplicitPath = path
 	return b
 }
 
+// WithKubeConfigBytes defines the content of the kubeconfig to be loaded, instead of reading it from files.
+// The kubeconfig loading rules are then ignored, while the overrides, like the context or impersonation, still apply.
+func (b ClientConfigBuilder) WithKubeConfigBytes(kubeConfig []byte) ClientConfigBuilder {
+	b.kubeConfig = kubeConfig
+	return b
+}
+
+// WithKubeConfigPaths defines a list of kubeconfig files to be merged, in order, when loading the configuration.
+// Equivalent to setting ${KUBECONFIG} to a colon-separated list of files.
+// An explicit path set with WithKubeConfigPath takes precedence over this list.
+func (b ClientConfigBuilder) WithKubeConfigPaths(paths ...string) ClientConfigBuilder {
+	b = b.Clone()
+	b.ClientConfigLoadingRules.Precedence = paths
+	return b
+}
+
+// WithInClusterConfig forces the use of the pod service account to reach the Kubernetes API.
+// The kubeconfig loading rules and overrides are ignored.
+func (b ClientConfigBuilder) WithInClusterConfig() ClientConfigBuilder {
+	b.inCluster = true
+	return b
+}
+
 // WithContext allows to define the kubernetes context to use.
 // Equivalent to `kubectl --context ${ctx}`
 func (b ClientConfigBuilder) WithContext(ctx string) ClientConfigBuilder {
+	b = b.Clone()
 	b.ConfigOverrides.CurrentContext = ctx
 	return b
 }
 
+// WithDefaultNamespace defines the namespace returned by Namespace when the selected context does not declare any.
+// It does not change the generated rest config.
+func (b ClientConfigBuilder) WithDefaultNamespace(namespace string) ClientConfigBuilder {
+	b.defaultNamespace = namespace
+	return b
+}
+
 // WithImpersonateUserName allows to create a client configuration with impersonation.
 // Equivalent to `kubectl --as ${user}`
 func (b ClientConfigBuilder) WithImpersonateUserName(userName string) ClientConfigBuilder {
+	b = b.Clone()
 	b.ConfigOverrides.AuthInfo.Impersonate = userName
 	return b
 }
@@ -83,39 +182,206 @@ func (b ClientConfigBuilder) WithImpersonateUserName(userName string) ClientConf
 // WithImpersonateUserGroups allows to create a client configuration with impersonation.
 // Equivalent to `kubectl --as my-user --as-group ${group}`
 func (b ClientConfigBuilder) WithImpersonateUserGroups(userGroups ...string) ClientConfigBuilder {
+	b = b.Clone()
 	b.ConfigOverrides.AuthInfo.ImpersonateGroups = userGroups
 	return b
 }
 
+// WithImpersonateExtra allows to create a client configuration with impersonation extra attributes.
+// Values accumulate across calls for the same key.
+// Equivalent to `kubectl --as my-user --as-user-extra ${key}=${value}`
+func (b ClientConfigBuilder) WithImpersonateExtra(key string, values ...string) ClientConfigBuilder {
+	b = b.Clone()
+	if b.ConfigOverrides.AuthInfo.ImpersonateUserExtra == nil {
+		b.ConfigOverrides.AuthInfo.ImpersonateUserExtra = map[string][]string{}
+	}
+	b.ConfigOverrides.AuthInfo.ImpersonateUserExtra[key] = append(b.ConfigOverrides.AuthInfo.ImpersonateUserExtra[key], values...)
+	return b
+}
+
+// WithContentType defines the content type used to communicate with the Kubernetes API.
+// Defaults to JSON when unset.
+func (b ClientConfigBuilder) WithContentType(contentType string) ClientConfigBuilder {
+	b.contentType = contentType
+	b.acceptContentTypes = contentType
+	return b
+}
+
+// WithTLSServerName defines the server name used to verify the API server certificate, when it is reached
+// through an address, like an IP, the certificate is not issued for.
+func (b ClientConfigBuilder) WithTLSServerName(name string) ClientConfigBuilder {
+	b.tlsServerName = name
+	return b
+}
+
+// WithRateLimiter defines the rate limiter throttling the requests to the Kubernetes API.
+// When set, the QPS and Burst of the generated config are ignored by the clients.
+func (b ClientConfigBuilder) WithRateLimiter(rateLimiter flowcontrol.RateLimiter) ClientConfigBuilder {
+	b.rateLimiter = rateLimiter
+	return b
+}
+
+// WithTransportWrapper adds a wrapper, like a tracing or metrics round tripper, to the transport of the clients.
+// Wrappers stack in call order: each one wraps the transport returned by the previously added ones.
+func (b ClientConfigBuilder) WithTransportWrapper(wrapper transport.WrapperFunc) ClientConfigBuilder {
+	b.transportWrappers = append(append([]transport.WrapperFunc{}, b.transportWrappers...), wrapper)
+	return b
+}
+
+// WithProtobuf negotiates protobuf with the Kubernetes API, falling back to JSON
+// for resources not supporting it, like custom resources.
+func (b ClientConfigBuilder) WithProtobuf() ClientConfigBuilder {
+	b = b.WithContentType(runtime.ContentTypeProtobuf)
+	b.acceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+	return b
+}
+
+// WithExecProvider allows to authenticate using an exec credential plugin, regardless of the kubeconfig content.
+// It can not be combined with WithBearerToken.
+func (b ClientConfigBuilder) WithExecProvider(exec *clientcmdapi.ExecConfig) ClientConfigBuilder {
+	b = b.Clone()
+	b.ConfigOverrides.AuthInfo.Exec = exec
+	return b
+}
+
+// WithBasicAuth allows to authenticate with a username and password, regardless of the kubeconfig content.
+// It can not be combined with WithBearerToken.
+func (b ClientConfigBuilder) WithBasicAuth(username, password string) ClientConfigBuilder {
+	b = b.Clone()
+	b.ConfigOverrides.AuthInfo.Username = username
+	b.ConfigOverrides.AuthInfo.Password = password
+	return b
+}
+
+// WithClientCertificate allows to authenticate with the given PEM encoded x509 client certificate and key,
+// regardless of the kubeconfig content.
+// Build fails when the certificate and key do not form a valid key pair.
+func (b ClientConfigBuilder) WithClientCertificate(certData, keyData []byte) ClientConfigBuilder {
+	b = b.Clone()
+	b.ConfigOverrides.AuthInfo.ClientCertificateData = certData
+	b.ConfigOverrides.AuthInfo.ClientKeyData = keyData
+	return b
+}
+
+func (b ClientConfigBuilder) validateAuthentication() error {
+	if b.bearerToken != "" && b.ConfigOverrides.AuthInfo.Exec != nil {
+		return errors.New("bearer token and exec provider can not be used together")
+	}
+	if b.bearerToken != "" && (b.ConfigOverrides.AuthInfo.Username != "" || b.ConfigOverrides.AuthInfo.Password != "") {
+		return errors.New("bearer token and basic authentication can not be used together")
+	}
+	authInfo := b.ConfigOverrides.AuthInfo
+	if len(authInfo.ClientCertificateData) > 0 || len(authInfo.ClientKeyData) > 0 {
+		if _, err := tls.X509KeyPair(authInfo.ClientCertificateData, authInfo.ClientKeyData); err != nil {
+			return fmt.Errorf("invalid client certificate: %w", err)
+		}
+	}
+	return nil
+}
+
 func (b ClientConfigBuilder) populateK8sClientToken(cfg *restclient.Config) error {
 	if cfg == nil {
 		return errors.New("nil rest config")
 	}
+	if b.bearerToken != "" {
+		cfg.BearerToken = b.bearerToken
+		return nil
+	}
 	// When there is no authentication in the config, try to discover it
-	if cfg.BearerToken == "" && cfg.BearerTokenFile == "" && cfg.TLSClientConfig.KeyFile == "" && len(cfg.TLSClientConfig.KeyData) == 0 && cfg.ExecProvider == nil {
-		kubeconfigPath := KubeConfigPath("")
-		if kubeconfigPath != "" {
-			tokenFile := filepath.Join(filepath.Dir(kubeconfigPath), b.tokenFile)
-			token, err := os.ReadFile(tokenFile)
-			if err == nil {
-				cfg.BearerToken = string(token)
-			}
+	if cfg.BearerToken == "" && cfg.BearerTokenFile == "" && cfg.Username == "" && cfg.TLSClientConfig.KeyFile == "" && len(cfg.TLSClientConfig.KeyData) == 0 && cfg.ExecProvider == nil {
+		if b.tokenFile == "" {
+			return nil
 		}
+		if b.kubeConfig != nil {
+			return fmt.Errorf("token file %q can not be located: the kubeconfig is not loaded from a file", b.tokenFile)
+		}
+		kubeconfigPath := KubeConfigPath(b.ClientConfigLoadingRules.ExplicitPath)
+		if kubeconfigPath == "" {
+			return fmt.Errorf("token file %q can not be located: no kubeconfig file found", b.tokenFile)
+		}
+		tokenFile := filepath.Join(filepath.Dir(kubeconfigPath), b.tokenFile)
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return fmt.Errorf("reading token file %q next to kubeconfig %q: %w", tokenFile, kubeconfigPath, err)
+		}
+		cfg.BearerToken = string(token)
+	}
+	return nil
+}
+
+func validateServerURL(server string) error {
+	u, err := url.Parse(server)
+	if err != nil {
+		return fmt.Errorf("invalid server URL %q: %w", server, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid server URL %q: expecting an http or https URL", server)
 	}
 	return nil
 }
 
+func (b ClientConfigBuilder) clientConfig() (clientcmd.ClientConfig, error) {
+	// the loading rules and overrides are adjusted below, keep the builder untouched
+	b = b.Clone()
+	if b.ConfigOverrides.ClusterInfo.Server != "" {
+		if err := validateServerURL(b.ConfigOverrides.ClusterInfo.Server); err != nil {
+			return nil, err
+		}
+	}
+	var clientConfig clientcmd.ClientConfig
+	if b.kubeConfig != nil {
+		config, err := clientcmd.Load(b.kubeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load kubeconfig: %w", err)
+		}
+		clientConfig = clientcmd.NewNonInteractiveClientConfig(*config, b.ConfigOverrides.CurrentContext, b.ConfigOverrides, nil)
+	} else {
+		if len(b.ClientConfigLoadingRules.Precedence) == 0 {
+			b.ClientConfigLoadingRules.ExplicitPath = KubeConfigPath(b.ClientConfigLoadingRules.ExplicitPath)
+		}
+
+		if b.ConfigOverrides.ClusterInfo.Server == "" && b.ClientConfigLoadingRules.ExplicitPath == "" && len(b.ClientConfigLoadingRules.Precedence) == 0 && b.DefaultServerURL != "" {
+			b.ConfigOverrides.ClusterInfo.Server = b.DefaultServerURL
+		}
+
+		clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(b.ClientConfigLoadingRules, b.ConfigOverrides)
+	}
+
+	if b.ConfigOverrides.CurrentContext != "" {
+		rawConfig, err := clientConfig.RawConfig()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := rawConfig.Contexts[b.ConfigOverrides.CurrentContext]; !ok {
+			return nil, fmt.Errorf("context %q not found", b.ConfigOverrides.CurrentContext)
+		}
+	}
+	return clientConfig, nil
+}
+
+func (b ClientConfigBuilder) loadConfig() (*restclient.Config, error) {
+	if b.inCluster {
+		cfg, err := restclient.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load in-cluster config: %w", err)
+		}
+		return cfg, nil
+	}
+	clientConfig, err := b.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return clientConfig.ClientConfig()
+}
+
 // Build generates a new rest client config for the current builder.
 func (b ClientConfigBuilder) Build() (*restclient.Config, error) {
-	cfg := &restclient.Config{}
-	var err error
-	b.ClientConfigLoadingRules.ExplicitPath = KubeConfigPath(b.ClientConfigLoadingRules.ExplicitPath)
-
-	if b.ConfigOverrides.ClusterInfo.Server == "" && b.ClientConfigLoadingRules.ExplicitPath == "" && b.DefaultServerURL != "" {
-		b.ConfigOverrides.ClusterInfo.Server = b.DefaultServerURL
+	err := b.validateAuthentication()
+	if err != nil {
+		return nil, err
 	}
 
-	cfg, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(b.ClientConfigLoadingRules, b.ConfigOverrides).ClientConfig()
+	cfg, err := b.loadConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -124,5 +390,152 @@ func (b ClientConfigBuilder) Build() (*restclient.Config, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if b.contentType != "" {
+		cfg.ContentType = b.contentType
+		cfg.AcceptContentTypes = b.acceptContentTypes
+	}
+	if b.tlsServerName != "" {
+		cfg.TLSClientConfig.ServerName = b.tlsServerName
+	}
+	if b.rateLimiter != nil {
+		cfg.RateLimiter = b.rateLimiter
+	}
+	for _, wrapper := range b.transportWrappers {
+		cfg.Wrap(wrapper)
+	}
 	return cfg, nil
 }
+
+// Namespace returns the namespace of the selected kubeconfig context.
+// Defaults to the namespace provided with WithDefaultNamespace, or `default`, when the context
+// does not declare any namespace.
+func (b ClientConfigBuilder) Namespace() (string, error) {
+	clientConfig, err := b.clientConfig()
+	if err != nil {
+		return "", err
+	}
+	namespace, overridden, err := clientConfig.Namespace()
+	if err != nil {
+		return "", err
+	}
+	if b.defaultNamespace == "" || overridden {
+		return namespace, nil
+	}
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return "", err
+	}
+	contextName := b.ConfigOverrides.CurrentContext
+	if contextName == "" {
+		contextName = rawConfig.CurrentContext
+	}
+	if context, ok := rawConfig.Contexts[contextName]; ok && context.Namespace != "" {
+		return namespace, nil
+	}
+	return b.defaultNamespace, nil
+}
+
+// Contexts returns the names of the contexts declared in the resolved kubeconfig, sorted alphabetically.
+func (b ClientConfigBuilder) Contexts() ([]string, error) {
+	clientConfig, err := b.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return nil, err
+	}
+	contexts := make([]string, 0, len(rawConfig.Contexts))
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
+// CurrentContext returns the name of the context used to connect to the cluster.
+// It is the one provided with WithContext when set, or the current context of the resolved kubeconfig otherwise.
+func (b ClientConfigBuilder) CurrentContext() (string, error) {
+	clientConfig, err := b.clientConfig()
+	if err != nil {
+		return "", err
+	}
+	if b.ConfigOverrides.CurrentContext != "" {
+		return b.ConfigOverrides.CurrentContext, nil
+	}
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return "", err
+	}
+	return rawConfig.CurrentContext, nil
+}
+
+// BuildDynamicClient generates a new dynamic client for the current builder.
+func (b ClientConfigBuilder) BuildDynamicClient() (dynamic.Interface, error) {
+	cfg, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// BuildDiscoveryClient generates a new discovery client for the current builder.
+func (b ClientConfigBuilder) BuildDiscoveryClient() (discovery.DiscoveryInterface, error) {
+	cfg, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	client, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Validate ensures the Kubernetes API can be reached with the current builder configuration.
+// The request is bound to ctx, so cancelling it or reaching its deadline stops waiting for the API.
+func (b ClientConfigBuilder) Validate(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("unable to reach the Kubernetes API: %w", err)
+	}
+	cfg, err := b.Build()
+	if err != nil {
+		return err
+	}
+	client, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	err = client.RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+	if err != nil {
+		return fmt.Errorf("unable to reach the Kubernetes API: %w", err)
+	}
+	return nil
+}
+
+// BuildRESTMapper generates a new REST mapper, backed by the discovery API, for the current builder.
+// The discovery results are lazily loaded on the first mapping and kept by the returned mapper.
+func (b ClientConfigBuilder) BuildRESTMapper() (meta.RESTMapper, error) {
+	client, err := b.BuildDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client)), nil
+}
+
+// BuildManager generates a new controller-runtime manager for the current builder, using scheme when not nil.
+func (b ClientConfigBuilder) BuildManager(scheme *runtime.Scheme, opts manager.Options) (manager.Manager, error) {
+	cfg, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	if scheme != nil {
+		opts.Scheme = scheme
+	}
+	return manager.New(cfg, opts)
+}
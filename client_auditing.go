@@ -0,0 +1,83 @@
+package k8s
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AuditingClient wraps a client so that record is called with the verb and the object of every write attempt,
+// before the call is delegated to the inner client.
+// It can wrap a ReadOnlyClient to record blocked writes.
+func AuditingClient(inner client.Client, record func(verb string, obj client.Object)) client.Client {
+	return &auditingClient{
+		Client: inner,
+		record: record,
+	}
+}
+
+type auditingClient struct {
+	client.Client
+	record func(verb string, obj client.Object)
+}
+
+type auditingSubResourceClient struct {
+	client.SubResourceClient
+	record func(verb string, obj client.Object)
+}
+
+var _ client.SubResourceClient = &auditingSubResourceClient{}
+
+// client must implement interface client.Client
+var _ client.Client = &auditingClient{}
+
+func (a *auditingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	a.record("Create", obj)
+	return a.Client.Create(ctx, obj, opts...)
+}
+
+func (a *auditingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	a.record("Update", obj)
+	return a.Client.Update(ctx, obj, opts...)
+}
+
+func (a *auditingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	a.record("Patch", obj)
+	return a.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (a *auditingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	a.record("Delete", obj)
+	return a.Client.Delete(ctx, obj, opts...)
+}
+
+func (a *auditingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	a.record("DeleteAllOf", obj)
+	return a.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (a *auditingClient) SubResource(resource string) client.SubResourceClient {
+	return &auditingSubResourceClient{
+		SubResourceClient: a.Client.SubResource(resource),
+		record:            a.record,
+	}
+}
+
+func (a *auditingClient) Status() client.StatusWriter {
+	return a.SubResource("status")
+}
+
+func (a *auditingSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	a.record("Create", obj)
+	return a.SubResourceClient.Create(ctx, obj, subResource, opts...)
+}
+
+func (a *auditingSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	a.record("Update", obj)
+	return a.SubResourceClient.Update(ctx, obj, opts...)
+}
+
+func (a *auditingSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	a.record("Patch", obj)
+	return a.SubResourceClient.Patch(ctx, obj, patch, opts...)
+}
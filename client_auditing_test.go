@@ -0,0 +1,87 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type auditRecord struct {
+	verb string
+	obj  client.Object
+}
+
+func TestAuditingClientRecordsWrites(t *testing.T) {
+	records := []auditRecord{}
+	record := func(verb string, obj client.Object) {
+		records = append(records, auditRecord{verb: verb, obj: obj})
+	}
+	existing := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "existing"}}
+	created := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "created"}}
+	all := &v1.Namespace{}
+
+	c := k8s.AuditingClient(fake.NewClientBuilder().WithObjects(existing).Build(), record)
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, created))
+	require.NoError(t, c.Update(ctx, existing))
+	require.NoError(t, c.Patch(ctx, existing, client.MergeFrom(existing)))
+	require.NoError(t, c.Delete(ctx, existing))
+	require.NoError(t, c.DeleteAllOf(ctx, all))
+	require.NoError(t, c.List(ctx, &v1.NamespaceList{}))
+
+	assert.Equal(t, []auditRecord{
+		{verb: "Create", obj: created},
+		{verb: "Update", obj: existing},
+		{verb: "Patch", obj: existing},
+		{verb: "Delete", obj: existing},
+		{verb: "DeleteAllOf", obj: all},
+	}, records)
+}
+
+func TestAuditingClientRecordsSubResourceWrites(t *testing.T) {
+	records := []auditRecord{}
+	record := func(verb string, obj client.Object) {
+		records = append(records, auditRecord{verb: verb, obj: obj})
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+
+	c := k8s.AuditingClient(fake.NewClientBuilder().WithObjects(pod).WithStatusSubresource(pod).Build(), record)
+	ctx := context.Background()
+	patch := client.MergeFrom(pod.DeepCopy())
+	pod.Status.Phase = v1.PodRunning
+	require.NoError(t, c.Status().Patch(ctx, pod, patch))
+	require.NoError(t, c.SubResource("status").Update(ctx, pod))
+
+	assert.Equal(t, []auditRecord{
+		{verb: "Patch", obj: pod},
+		{verb: "Update", obj: pod},
+	}, records)
+
+	stored := &v1.Pod{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pod), stored))
+	assert.Equal(t, v1.PodRunning, stored.Status.Phase)
+}
+
+func TestAuditingClientComposesWithReadOnlyClient(t *testing.T) {
+	verbs := []string{}
+	c := k8s.AuditingClient(
+		k8s.ReadOnlyClient(fake.NewClientBuilder().Build()),
+		func(verb string, obj client.Object) {
+			verbs = append(verbs, verb)
+		},
+	)
+	err := c.Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+	assert.EqualError(t, err, "Create not allowed in read-only mode")
+	assert.Equal(t, []string{"Create"}, verbs)
+
+	namespaces := &v1.NamespaceList{}
+	require.NoError(t, c.List(context.Background(), namespaces))
+	assert.Empty(t, namespaces.Items)
+}
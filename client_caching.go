@@ -0,0 +1,201 @@
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// CachingReadClient wraps a client so that successful Get results are cached for ttl, keyed by the object
+// group, version, kind and key.
+// Lists and Gets with options are delegated untouched. Writes are delegated and invalidate the cached entries
+// they affect, and Get results read while a write was in flight are not cached.
+// It is safe for concurrent use.
+func CachingReadClient(inner client.Client, ttl time.Duration) client.Client {
+	return &cachingReadClient{
+		Client: inner,
+		cache: &readCache{
+			ttl:     ttl,
+			entries: map[readCacheKey]readCacheEntry{},
+		},
+	}
+}
+
+type readCacheKey struct {
+	gvk schema.GroupVersionKind
+	key client.ObjectKey
+	// the go type is part of the key so that a typed object is never copied into an unstructured one
+	tpe reflect.Type
+}
+
+type readCacheEntry struct {
+	obj     runtime.Object
+	expires time.Time
+}
+
+type readCache struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[readCacheKey]readCacheEntry
+	// generation is bumped by every invalidation, so that objects read before it are not cached
+	generation uint64
+}
+
+func (c *readCache) get(key readCacheKey) (runtime.Object, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.obj.DeepCopyObject(), true
+}
+
+func (c *readCache) currentGeneration() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.generation
+}
+
+// set caches obj unless an invalidation happened since generation was read.
+func (c *readCache) set(key readCacheKey, obj runtime.Object, generation uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.generation != generation {
+		return
+	}
+	c.entries[key] = readCacheEntry{obj: obj.DeepCopyObject(), expires: time.Now().Add(c.ttl)}
+}
+
+func (c *readCache) invalidate(matches func(readCacheKey) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	for key := range c.entries {
+		if matches(key) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+type cachingReadClient struct {
+	client.Client
+	cache *readCache
+}
+
+type cachingReadSubresourceClient struct {
+	client.SubResourceClient
+	parent *cachingReadClient
+}
+
+var _ client.SubResourceClient = &cachingReadSubresourceClient{}
+
+// client must implement interface client.Client
+var _ client.Client = &cachingReadClient{}
+
+func (c *cachingReadClient) keyFor(key client.ObjectKey, obj client.Object) (readCacheKey, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Client.Scheme())
+	if err != nil {
+		return readCacheKey{}, err
+	}
+	return readCacheKey{gvk: gvk, key: key, tpe: reflect.TypeOf(obj)}, nil
+}
+
+func (c *cachingReadClient) invalidate(obj client.Object) {
+	key, err := c.keyFor(client.ObjectKeyFromObject(obj), obj)
+	if err != nil {
+		// the kind is unknown, there is no way to tell which entries are affected
+		c.cache.invalidate(func(readCacheKey) bool { return true })
+		return
+	}
+	c.cache.invalidate(func(k readCacheKey) bool {
+		return k.gvk == key.gvk && k.key == key.key
+	})
+}
+
+func (c *cachingReadClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if len(opts) > 0 {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	cacheKey, err := c.keyFor(key, obj)
+	if err != nil {
+		return c.Client.Get(ctx, key, obj)
+	}
+	if cached, ok := c.cache.get(cacheKey); ok {
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(cached).Elem())
+		return nil
+	}
+	generation := c.cache.currentGeneration()
+	err = c.Client.Get(ctx, key, obj)
+	if err != nil {
+		return err
+	}
+	c.cache.set(cacheKey, obj, generation)
+	return nil
+}
+
+func (c *cachingReadClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	defer c.invalidate(obj)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *cachingReadClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	defer c.invalidate(obj)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *cachingReadClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	defer c.invalidate(obj)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *cachingReadClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	defer c.invalidate(obj)
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *cachingReadClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	defer func() {
+		gvk, err := apiutil.GVKForObject(obj, c.Client.Scheme())
+		c.cache.invalidate(func(k readCacheKey) bool {
+			return err != nil || k.gvk == gvk
+		})
+	}()
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *cachingReadClient) SubResource(resource string) client.SubResourceClient {
+	return &cachingReadSubresourceClient{
+		SubResourceClient: c.Client.SubResource(resource),
+		parent:            c,
+	}
+}
+
+func (c *cachingReadClient) Status() client.StatusWriter {
+	return c.SubResource("status")
+}
+
+func (c *cachingReadSubresourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	defer c.parent.invalidate(obj)
+	return c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+}
+
+func (c *cachingReadSubresourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	defer c.parent.invalidate(obj)
+	return c.SubResourceClient.Update(ctx, obj, opts...)
+}
+
+func (c *cachingReadSubresourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	defer c.parent.invalidate(obj)
+	return c.SubResourceClient.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,148 @@
+package k8s_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newCountingClient(gets *int, objs ...client.Object) client.WithWatch {
+	lock := sync.Mutex{}
+	return fake.NewClientBuilder().WithObjects(objs...).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			lock.Lock()
+			*gets++
+			lock.Unlock()
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+}
+
+func TestCachingReadClientServesRepeatedGetsFromCache(t *testing.T) {
+	gets := 0
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}, Data: map[string]string{"key": "value"}}
+	c := k8s.CachingReadClient(newCountingClient(&gets, cm), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		got := &v1.ConfigMap{}
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), got))
+		assert.Equal(t, "value", got.Data["key"])
+	}
+	assert.Equal(t, 1, gets)
+
+	t.Run("cached objects are not shared with callers", func(t *testing.T) {
+		got := &v1.ConfigMap{}
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), got))
+		got.Data["key"] = "changed"
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), got))
+		assert.Equal(t, "value", got.Data["key"])
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		gets = 0
+		key := client.ObjectKey{Name: "missing", Namespace: "default"}
+		assert.Error(t, c.Get(context.Background(), key, &v1.ConfigMap{}))
+		assert.Error(t, c.Get(context.Background(), key, &v1.ConfigMap{}))
+		assert.Equal(t, 2, gets)
+	})
+}
+
+func TestCachingReadClientExpiresEntries(t *testing.T) {
+	gets := 0
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	c := k8s.CachingReadClient(newCountingClient(&gets, cm), 10*time.Millisecond)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), &v1.ConfigMap{}))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), &v1.ConfigMap{}))
+	assert.Equal(t, 2, gets)
+}
+
+func TestCachingReadClientInvalidatesOnWrites(t *testing.T) {
+	gets := 0
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}, Data: map[string]string{"key": "value"}}
+	c := k8s.CachingReadClient(newCountingClient(&gets, cm), time.Minute)
+
+	got := &v1.ConfigMap{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), got))
+	got.Data["key"] = "updated"
+	require.NoError(t, c.Update(context.Background(), got))
+
+	got = &v1.ConfigMap{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), got))
+	assert.Equal(t, "updated", got.Data["key"])
+	assert.Equal(t, 2, gets)
+
+	require.NoError(t, c.Delete(context.Background(), got))
+	assert.Error(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), &v1.ConfigMap{}))
+}
+
+func TestCachingReadClientDoesNotCacheObjectsReadDuringWrites(t *testing.T) {
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}, Data: map[string]string{"key": "value"}}
+	var c client.Client
+	updated := false
+	inner := fake.NewClientBuilder().WithObjects(cm).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, inner client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			err := inner.Get(ctx, key, obj, opts...)
+			if !updated {
+				// the object is updated after it was read but before the read result is cached
+				updated = true
+				latest := &v1.ConfigMap{}
+				require.NoError(t, inner.Get(ctx, key, latest))
+				latest.Data["key"] = "updated"
+				require.NoError(t, c.Update(ctx, latest))
+			}
+			return err
+		},
+	}).Build()
+	c = k8s.CachingReadClient(inner, time.Minute)
+
+	got := &v1.ConfigMap{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), got))
+	assert.Equal(t, "value", got.Data["key"])
+
+	got = &v1.ConfigMap{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), got))
+	assert.Equal(t, "updated", got.Data["key"])
+}
+
+func TestCachingReadClientBypassesCacheForGetOptions(t *testing.T) {
+	gets := 0
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	c := k8s.CachingReadClient(newCountingClient(&gets, cm), time.Minute)
+	withOptions := &client.GetOptions{Raw: &metav1.GetOptions{ResourceVersion: "0"}}
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), &v1.ConfigMap{}, withOptions))
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), &v1.ConfigMap{}))
+	assert.Equal(t, 2, gets, "gets with options must not fill the cache")
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), &v1.ConfigMap{}, withOptions))
+	assert.Equal(t, 3, gets, "gets with options must not be served from the cache")
+}
+
+func TestCachingReadClientIsSafeForConcurrentUse(t *testing.T) {
+	gets := 0
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	c := k8s.CachingReadClient(newCountingClient(&gets, cm), time.Minute)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(cm), &v1.ConfigMap{}))
+			assert.NoError(t, c.Patch(context.Background(), cm.DeepCopy(), client.MergeFrom(cm)))
+		}()
+	}
+	wg.Wait()
+}
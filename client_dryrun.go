@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DryRunClient wraps a client so that every write is sent to the API server in dry-run mode.
+// Writes are validated server-side but never persisted. Reads are delegated untouched.
+func DryRunClient(client client.Client) client.Client {
+	return &dryRunClient{
+		Client: client,
+	}
+}
+
+type dryRunClient struct {
+	client.Client
+}
+
+type dryRunSubresourceClient struct {
+	client.SubResourceClient
+}
+
+var _ client.SubResourceClient = &dryRunSubresourceClient{}
+
+// client must implement interface client.Client
+var _ client.Client = &dryRunClient{}
+
+func (d *dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return d.Client.Create(ctx, obj, append(append([]client.CreateOption{}, opts...), client.DryRunAll)...)
+}
+
+func (d *dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return d.Client.Update(ctx, obj, append(append([]client.UpdateOption{}, opts...), client.DryRunAll)...)
+}
+
+func (d *dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return d.Client.Patch(ctx, obj, patch, append(append([]client.PatchOption{}, opts...), client.DryRunAll)...)
+}
+
+func (d *dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return d.Client.Delete(ctx, obj, append(append([]client.DeleteOption{}, opts...), client.DryRunAll)...)
+}
+
+func (d *dryRunClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return d.Client.DeleteAllOf(ctx, obj, append(append([]client.DeleteAllOfOption{}, opts...), client.DryRunAll)...)
+}
+
+func (d *dryRunClient) SubResource(resource string) client.SubResourceClient {
+	return &dryRunSubresourceClient{
+		SubResourceClient: d.Client.SubResource(resource),
+	}
+}
+
+func (d *dryRunClient) Status() client.StatusWriter {
+	return d.SubResource("status")
+}
+
+func (d *dryRunSubresourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return d.SubResourceClient.Create(ctx, obj, subResource, append(append([]client.SubResourceCreateOption{}, opts...), client.DryRunAll)...)
+}
+
+func (d *dryRunSubresourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return d.SubResourceClient.Update(ctx, obj, append(append([]client.SubResourceUpdateOption{}, opts...), client.DryRunAll)...)
+}
+
+func (d *dryRunSubresourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return d.SubResourceClient.Patch(ctx, obj, patch, append(append([]client.SubResourcePatchOption{}, opts...), client.DryRunAll)...)
+}
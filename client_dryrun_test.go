@@ -0,0 +1,78 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestDryRunClientForwardsDryRun(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	dryRuns := map[string][]string{}
+	inner := fake.NewClientBuilder().WithObjects(ns).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			dryRuns["Create"] = (&client.CreateOptions{}).ApplyOptions(opts).DryRun
+			return nil
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			dryRuns["Update"] = (&client.UpdateOptions{}).ApplyOptions(opts).DryRun
+			return nil
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			dryRuns["Patch"] = (&client.PatchOptions{}).ApplyOptions(opts).DryRun
+			return nil
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			dryRuns["Delete"] = (&client.DeleteOptions{}).ApplyOptions(opts).DryRun
+			return nil
+		},
+		DeleteAllOf: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteAllOfOption) error {
+			dryRuns["DeleteAllOf"] = (&client.DeleteAllOfOptions{}).ApplyOptions(opts).DryRun
+			return nil
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			dryRuns["StatusUpdate"] = (&client.SubResourceUpdateOptions{}).ApplyOptions(opts).DryRun
+			return nil
+		},
+	}).Build()
+	c := k8s.DryRunClient(inner)
+	ctx := context.Background()
+
+	require.NoError(t, c.Create(ctx, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}}))
+	require.NoError(t, c.Update(ctx, ns))
+	require.NoError(t, c.Patch(ctx, ns, client.MergeFrom(ns)))
+	require.NoError(t, c.Delete(ctx, ns))
+	require.NoError(t, c.DeleteAllOf(ctx, &v1.Namespace{}))
+	require.NoError(t, c.Status().Update(ctx, ns))
+
+	for _, verb := range []string{"Create", "Update", "Patch", "Delete", "DeleteAllOf", "StatusUpdate"} {
+		assert.Equal(t, []string{metav1.DryRunAll}, dryRuns[verb], verb)
+	}
+}
+
+func TestDryRunClientReadsPassThrough(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	c := k8s.DryRunClient(fake.NewClientBuilder().WithObjects(ns).Build())
+
+	namespaces := &v1.NamespaceList{}
+	require.NoError(t, c.List(context.Background(), namespaces))
+	require.Len(t, namespaces.Items, 1)
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(ns), &v1.Namespace{}))
+}
+
+func TestDryRunClientDoesNotWriteToCallerOptions(t *testing.T) {
+	c := k8s.DryRunClient(fake.NewClientBuilder().Build())
+	opts := make([]client.CreateOption, 1, 2)
+	opts[0] = client.FieldOwner("test")
+
+	require.NoError(t, c.Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, opts...))
+	assert.Nil(t, opts[:2][1])
+}
@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LoggingClient wraps a client so that every Get, List, Create, Update, Patch, Delete and DeleteAllOf call is
+// logged with the object key and the call duration.
+// Successful calls are logged at V(1), failed calls are logged as errors.
+func LoggingClient(inner client.Client, logger logr.Logger) client.Client {
+	return &loggingClient{
+		Client: inner,
+		logger: logger,
+	}
+}
+
+type loggingClient struct {
+	client.Client
+	logger logr.Logger
+}
+
+// client must implement interface client.Client
+var _ client.Client = &loggingClient{}
+
+func (l *loggingClient) log(verb string, key client.ObjectKey, start time.Time, err error) {
+	keysAndValues := []interface{}{"verb", verb, "namespace", key.Namespace, "name", key.Name, "duration", time.Since(start)}
+	if err != nil {
+		l.logger.Error(err, "kubernetes client call failed", keysAndValues...)
+		return
+	}
+	l.logger.V(1).Info("kubernetes client call", keysAndValues...)
+}
+
+func (l *loggingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	start := time.Now()
+	err := l.Client.Get(ctx, key, obj, opts...)
+	l.log("Get", key, start, err)
+	return err
+}
+
+func (l *loggingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	start := time.Now()
+	err := l.Client.List(ctx, list, opts...)
+	l.log("List", client.ObjectKey{Namespace: (&client.ListOptions{}).ApplyOptions(opts).Namespace}, start, err)
+	return err
+}
+
+func (l *loggingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	start := time.Now()
+	err := l.Client.Create(ctx, obj, opts...)
+	l.log("Create", client.ObjectKeyFromObject(obj), start, err)
+	return err
+}
+
+func (l *loggingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	start := time.Now()
+	err := l.Client.Update(ctx, obj, opts...)
+	l.log("Update", client.ObjectKeyFromObject(obj), start, err)
+	return err
+}
+
+func (l *loggingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	start := time.Now()
+	err := l.Client.Patch(ctx, obj, patch, opts...)
+	l.log("Patch", client.ObjectKeyFromObject(obj), start, err)
+	return err
+}
+
+func (l *loggingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	start := time.Now()
+	err := l.Client.Delete(ctx, obj, opts...)
+	l.log("Delete", client.ObjectKeyFromObject(obj), start, err)
+	return err
+}
+
+func (l *loggingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	start := time.Now()
+	err := l.Client.DeleteAllOf(ctx, obj, opts...)
+	l.log("DeleteAllOf", client.ObjectKey{Namespace: (&client.DeleteAllOfOptions{}).ApplyOptions(opts).Namespace}, start, err)
+	return err
+}
@@ -0,0 +1,94 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type logEntry struct {
+	level  int
+	err    error
+	msg    string
+	values map[string]interface{}
+}
+
+type recordingSink struct {
+	entries *[]logEntry
+}
+
+func (s recordingSink) Init(logr.RuntimeInfo) {}
+
+func (s recordingSink) Enabled(int) bool { return true }
+
+func (s recordingSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.record(logEntry{level: level, msg: msg}, keysAndValues)
+}
+
+func (s recordingSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.record(logEntry{err: err, msg: msg}, keysAndValues)
+}
+
+func (s recordingSink) WithValues(...interface{}) logr.LogSink { return s }
+
+func (s recordingSink) WithName(string) logr.LogSink { return s }
+
+func (s recordingSink) record(entry logEntry, keysAndValues []interface{}) {
+	entry.values = map[string]interface{}{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		entry.values[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+	*s.entries = append(*s.entries, entry)
+}
+
+func TestLoggingClientLogsGetWithDuration(t *testing.T) {
+	entries := []logEntry{}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	c := k8s.LoggingClient(fake.NewClientBuilder().WithObjects(ns).Build(), logr.New(recordingSink{entries: &entries}))
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(ns), &v1.Namespace{}))
+
+	require.Len(t, entries, 1)
+	assert.Equal(t, 1, entries[0].level)
+	assert.NoError(t, entries[0].err)
+	assert.Equal(t, "Get", entries[0].values["verb"])
+	assert.Equal(t, "test", entries[0].values["name"])
+	assert.IsType(t, time.Duration(0), entries[0].values["duration"])
+}
+
+func TestLoggingClientLogsFailuresAsErrors(t *testing.T) {
+	entries := []logEntry{}
+	c := k8s.LoggingClient(fake.NewClientBuilder().Build(), logr.New(recordingSink{entries: &entries}))
+
+	err := c.Delete(context.Background(), &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "default"}})
+	require.Error(t, err)
+
+	require.Len(t, entries, 1)
+	assert.Equal(t, err, entries[0].err)
+	assert.Equal(t, "Delete", entries[0].values["verb"])
+	assert.Equal(t, "default", entries[0].values["namespace"])
+	assert.Equal(t, "missing", entries[0].values["name"])
+}
+
+func TestLoggingClientDelegatesWrites(t *testing.T) {
+	entries := []logEntry{}
+	inner := fake.NewClientBuilder().Build()
+	c := k8s.LoggingClient(inner, logr.New(recordingSink{entries: &entries}))
+
+	require.NoError(t, c.Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "created"}}))
+	require.NoError(t, c.List(context.Background(), &v1.NamespaceList{}))
+	assert.NoError(t, inner.Get(context.Background(), client.ObjectKey{Name: "created"}, &v1.Namespace{}))
+
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Create", entries[0].values["verb"])
+	assert.Equal(t, "List", entries[1].values["verb"])
+}
@@ -5,9 +5,27 @@ import (
 	"errors"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ReadOnlyError is the error returned by default when a write is blocked by a read-only client.
+// Use errors.As to find out which verb was blocked.
+type ReadOnlyError struct {
+	Verb string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s not allowed in read-only mode", e.Verb)
+}
+
+func newReadOnlyError(verb string) error {
+	return &ReadOnlyError{Verb: verb}
+}
+
 func WithErrorBuilder(newError func(string) error) func(c *readOnlyClient) {
 	return func(c *readOnlyClient) {
 		c.newError = newError
@@ -20,16 +38,49 @@ func WithNoError() func(c *readOnlyClient) {
 	})
 }
 func WithError() func(c *readOnlyClient) {
-	return WithErrorBuilder(func(method string) error {
-		return fmt.Errorf("%s not allowed in read-only mode", method)
-	})
+	return WithErrorBuilder(newReadOnlyError)
+}
+
+// WithBlockedCallback registers a function called with the verb of every blocked write, right before the
+// read-only error is returned.
+func WithBlockedCallback(onBlocked func(verb string)) func(c *readOnlyClient) {
+	return func(c *readOnlyClient) {
+		c.onBlocked = onBlocked
+	}
+}
+
+// WithWritableNamespaces lets writes to objects in the listed namespaces be delegated to the wrapped client
+// instead of being blocked. Writes to cluster-scoped objects remain blocked.
+// When combined with WithAllowedVerbs, only the allowed verbs are delegated, and only in these namespaces.
+func WithWritableNamespaces(namespaces ...string) func(c *readOnlyClient) {
+	return func(c *readOnlyClient) {
+		if c.writableNamespaces == nil {
+			c.writableNamespaces = map[string]struct{}{}
+		}
+		for _, namespace := range namespaces {
+			c.writableNamespaces[namespace] = struct{}{}
+		}
+	}
+}
+
+// WithAllowedVerbs lets the listed write verbs, like "Create" or "Update", be delegated to the wrapped client
+// instead of being blocked. With WithWritableNamespaces, they are restricted to the writable namespaces.
+func WithAllowedVerbs(verbs ...string) func(c *readOnlyClient) {
+	return func(c *readOnlyClient) {
+		if c.allowedVerbs == nil {
+			c.allowedVerbs = map[string]struct{}{}
+		}
+		for _, verb := range verbs {
+			c.allowedVerbs[verb] = struct{}{}
+		}
+	}
 }
 
 func ReadOnlyClient(client client.Client, mutators ...func(c *readOnlyClient)) client.Client {
 	c := &readOnlyClient{
 		Client: client,
-		newError: func(method string) error {
-			return fmt.Errorf("%s not allowed in read-only mode", method)
+		readOnlyRules: readOnlyRules{
+			newError: newReadOnlyError,
 		},
 	}
 	for _, m := range mutators {
@@ -38,14 +89,64 @@ func ReadOnlyClient(client client.Client, mutators ...func(c *readOnlyClient)) c
 	return c
 }
 
+// ReadOnlyWatchClient behaves like ReadOnlyClient but keeps the Watch method of the wrapped client.
+// Watches are read operations and are always delegated.
+func ReadOnlyWatchClient(client client.WithWatch, mutators ...func(c *readOnlyClient)) client.WithWatch {
+	return &readOnlyWatchClient{
+		readOnlyClient: ReadOnlyClient(client, mutators...).(*readOnlyClient),
+		watcher:        client,
+	}
+}
+
+var (
+	errNilClient            = errors.New("client is nil")
+	errNilSubResourceClient = errors.New("status client is nil")
+)
+
+type readOnlyRules struct {
+	newError           func(method string) error
+	allowedVerbs       map[string]struct{}
+	writableNamespaces map[string]struct{}
+	onBlocked          func(verb string)
+}
+
+func (r readOnlyRules) blocked(verb string) error {
+	if r.onBlocked != nil {
+		r.onBlocked(verb)
+	}
+	if r.newError == nil {
+		return newReadOnlyError(verb)
+	}
+	return r.newError(verb)
+}
+
+func (r readOnlyRules) allows(verb, namespace string) bool {
+	_, verbAllowed := r.allowedVerbs[verb]
+	if r.writableNamespaces == nil {
+		return verbAllowed
+	}
+	if namespace == "" {
+		return false
+	}
+	if _, ok := r.writableNamespaces[namespace]; !ok {
+		return false
+	}
+	return r.allowedVerbs == nil || verbAllowed
+}
+
 type readOnlyClient struct {
 	client.Client
-	newError func(method string) error
+	readOnlyRules
+}
+
+type readOnlyWatchClient struct {
+	*readOnlyClient
+	watcher client.WithWatch
 }
 
 type readOnlySubresourceClient struct {
 	client.SubResourceClient
-	newError func(method string) error
+	readOnlyRules
 }
 
 var _ client.SubResourceClient = &readOnlySubresourceClient{}
@@ -53,49 +154,135 @@ var _ client.SubResourceClient = &readOnlySubresourceClient{}
 // client must implement interface client.Client
 var _ client.Client = &readOnlyClient{}
 
+var _ client.WithWatch = &readOnlyWatchClient{}
+
+func (r *readOnlyWatchClient) Watch(ctx context.Context, obj client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	if r == nil || r.watcher == nil {
+		return nil, errNilClient
+	}
+	return r.watcher.Watch(ctx, obj, opts...)
+}
+
+func (r *readOnlyClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if r == nil || r.Client == nil {
+		return errNilClient
+	}
+	return r.Client.Get(ctx, key, obj, opts...)
+}
+
+func (r *readOnlyClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if r == nil || r.Client == nil {
+		return errNilClient
+	}
+	return r.Client.List(ctx, list, opts...)
+}
+
+// Scheme returns the scheme of the wrapped client, or nil when there is no wrapped client.
+func (r *readOnlyClient) Scheme() *runtime.Scheme {
+	if r == nil || r.Client == nil {
+		return nil
+	}
+	return r.Client.Scheme()
+}
+
+// RESTMapper returns the REST mapper of the wrapped client, or nil when there is no wrapped client.
+func (r *readOnlyClient) RESTMapper() meta.RESTMapper {
+	if r == nil || r.Client == nil {
+		return nil
+	}
+	return r.Client.RESTMapper()
+}
+
+func (r *readOnlyClient) GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if r == nil || r.Client == nil {
+		return schema.GroupVersionKind{}, errNilClient
+	}
+	return r.Client.GroupVersionKindFor(obj)
+}
+
+func (r *readOnlyClient) IsObjectNamespaced(obj runtime.Object) (bool, error) {
+	if r == nil || r.Client == nil {
+		return false, errNilClient
+	}
+	return r.Client.IsObjectNamespaced(obj)
+}
+
 func (r *readOnlyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
 	if r == nil {
-		return errors.New("client is nil")
+		return errNilClient
 	}
-	return r.newError("Create")
+	if r.allows("Create", obj.GetNamespace()) {
+		if r.Client == nil {
+			return errNilClient
+		}
+		return r.Client.Create(ctx, obj, opts...)
+	}
+	return r.blocked("Create")
 }
 
 func (r *readOnlyClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
 	if r == nil {
-		return errors.New("client is nil")
+		return errNilClient
+	}
+	if r.allows("Update", obj.GetNamespace()) {
+		if r.Client == nil {
+			return errNilClient
+		}
+		return r.Client.Update(ctx, obj, opts...)
 	}
-	return r.newError("Update")
+	return r.blocked("Update")
 }
 
 func (r *readOnlyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
 	if r == nil {
-		return errors.New("client is nil")
+		return errNilClient
 	}
-	return r.newError("Patch")
+	if r.allows("Patch", obj.GetNamespace()) {
+		if r.Client == nil {
+			return errNilClient
+		}
+		return r.Client.Patch(ctx, obj, patch, opts...)
+	}
+	return r.blocked("Patch")
 }
 
 func (r *readOnlyClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
 	if r == nil {
-		return errors.New("client is nil")
+		return errNilClient
+	}
+	if r.allows("Delete", obj.GetNamespace()) {
+		if r.Client == nil {
+			return errNilClient
+		}
+		return r.Client.Delete(ctx, obj, opts...)
 	}
-	return r.newError("Delete")
+	return r.blocked("Delete")
 }
 
 func (r *readOnlyClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
 	if r == nil {
-		return errors.New("client is nil")
+		return errNilClient
 	}
-	return r.newError("DeleteAllOf")
+	if r.allows("DeleteAllOf", (&client.DeleteAllOfOptions{}).ApplyOptions(opts).Namespace) {
+		if r.Client == nil {
+			return errNilClient
+		}
+		return r.Client.DeleteAllOf(ctx, obj, opts...)
+	}
+	return r.blocked("DeleteAllOf")
 }
 
 func (r *readOnlyClient) SubResource(resource string) client.SubResourceClient {
+	if r == nil {
+		return &readOnlySubresourceClient{}
+	}
 	var subResourceClient client.SubResourceClient
-	if r != nil && r.Client != nil {
+	if r.Client != nil {
 		subResourceClient = r.Client.SubResource(resource)
 	}
 	return &readOnlySubresourceClient{
 		SubResourceClient: subResourceClient,
-		newError:          r.newError,
+		readOnlyRules:     r.readOnlyRules,
 	}
 }
 
@@ -103,21 +290,46 @@ func (r *readOnlyClient) Status() client.StatusWriter {
 	return r.SubResource("status")
 }
 
+func (r *readOnlySubresourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	if r == nil || r.SubResourceClient == nil {
+		return errNilSubResourceClient
+	}
+	return r.SubResourceClient.Get(ctx, obj, subResource, opts...)
+}
+
 func (r *readOnlySubresourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
 	if r == nil {
-		return errors.New("status client is nil")
+		return errNilSubResourceClient
+	}
+	if r.allows("Update", obj.GetNamespace()) {
+		if r.SubResourceClient == nil {
+			return errNilSubResourceClient
+		}
+		return r.SubResourceClient.Update(ctx, obj, opts...)
 	}
-	return r.newError("Update")
+	return r.blocked("Update")
 }
 func (r *readOnlySubresourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
 	if r == nil {
-		return errors.New("status client is nil")
+		return errNilSubResourceClient
 	}
-	return r.newError("Update")
+	if r.allows("Create", obj.GetNamespace()) {
+		if r.SubResourceClient == nil {
+			return errNilSubResourceClient
+		}
+		return r.SubResourceClient.Create(ctx, obj, subResource, opts...)
+	}
+	return r.blocked("Create")
 }
 func (r *readOnlySubresourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
 	if r == nil {
-		return errors.New("status client is nil")
+		return errNilSubResourceClient
+	}
+	if r.allows("Patch", obj.GetNamespace()) {
+		if r.SubResourceClient == nil {
+			return errNilSubResourceClient
+		}
+		return r.SubResourceClient.Patch(ctx, obj, patch, opts...)
 	}
-	return r.newError("Update")
+	return r.blocked("Patch")
 }
@@ -2,21 +2,26 @@ package k8s_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	k8s "github.com/adevinta/go-k8s-toolkit"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	ctrl "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func testAllWriteMethodsAreImplemented(t *testing.T, client interface{}, knownReadOnlyMethods map[string]struct{}) {
+// testAllMethodsAreImplemented ensures every method is implemented by the read-only wrapper itself.
+// Methods promoted from the embedded client would panic when called on a nil wrapper.
+func testAllMethodsAreImplemented(t *testing.T, client interface{}) {
 	t.Helper()
 	t.Run(fmt.Sprintf("%T", client), func(t *testing.T) {
 		require.NotNil(t, client)
@@ -34,49 +39,49 @@ func testAllWriteMethodsAreImplemented(t *testing.T, client interface{}, knownRe
 						args = append(args, reflect.New(argType).Elem())
 					}
 				}
-				if _, ok := knownReadOnlyMethods[method.Name]; ok {
-					// Panics as the original client is a nil pointer
-					assert.Panicsf(t, func() {
-						method.Func.Call(args)
-					},
-						"The original client should be called for known read-only method %s",
-						method.Name,
-					)
-				} else {
-					assert.NotPanicsf(t, func() {
-						method.Func.Call(args)
-					},
-						"The original client should not be called for write method %s",
-						method.Name,
-					)
-				}
+				assert.NotPanicsf(t, func() {
+					method.Func.Call(args)
+				},
+					"Method %s should be implemented by the read-only client",
+					method.Name,
+				)
 			})
 		}
 	})
 }
 
-func TestAllWriteFieldsAreImplemented(t *testing.T) {
-
+func TestAllMethodsAreImplemented(t *testing.T) {
 	client := k8s.ReadOnlyClient(nil)
-	client.Status()
-	testAllWriteMethodsAreImplemented(t, client, map[string]struct{}{
-		"Get":                 {},
-		"List":                {},
-		"RESTMapper":          {},
-		"Scheme":              {},
-		"Status":              {}, // This one is tested right below
-		"SubResource":         {}, // This one is tested right below
-		"GroupVersionKindFor": {},
-		"IsObjectNamespaced":  {},
-	})
-	testAllWriteMethodsAreImplemented(t, client.Status(), map[string]struct{}{
-		"Get":  {},
-		"List": {},
-	})
-	testAllWriteMethodsAreImplemented(t, client.SubResource("any"), map[string]struct{}{
-		"Get":  {},
-		"List": {},
-	})
+	testAllMethodsAreImplemented(t, client)
+	testAllMethodsAreImplemented(t, client.Status())
+	testAllMethodsAreImplemented(t, client.SubResource("any"))
+}
+
+func TestReadOnlyClientWithoutWrappedClient(t *testing.T) {
+	cl := k8s.ReadOnlyClient(nil, k8s.WithAllowedVerbs("Create"))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}}
+
+	assert.Nil(t, cl.Scheme())
+	assert.Nil(t, cl.RESTMapper())
+	_, err := cl.GroupVersionKindFor(pod)
+	assert.EqualError(t, err, "client is nil")
+	_, err = cl.IsObjectNamespaced(pod)
+	assert.EqualError(t, err, "client is nil")
+	assert.EqualError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(pod), pod), "client is nil")
+	assert.EqualError(t, cl.List(context.Background(), &v1.PodList{}), "client is nil")
+	assert.EqualError(t, cl.Create(context.Background(), pod), "client is nil")
+	assert.EqualError(t, cl.Delete(context.Background(), pod), "Delete not allowed in read-only mode")
+
+	status := cl.Status()
+	require.NotNil(t, status)
+	assert.EqualError(t, cl.SubResource("status").Get(context.Background(), pod, &v1.Pod{}), "status client is nil")
+	assert.EqualError(t, status.Create(context.Background(), pod, &v1.Pod{}), "status client is nil")
+	assert.EqualError(t, status.Update(context.Background(), pod), "Update not allowed in read-only mode")
+
+	watchClient := k8s.ReadOnlyWatchClient(nil)
+	assert.Nil(t, watchClient.RESTMapper())
+	_, err = watchClient.Watch(context.Background(), &v1.PodList{})
+	assert.EqualError(t, err, "client is nil")
 }
 
 func TestReadOnlyClientDoesNotCallCreate(t *testing.T) {
@@ -89,6 +94,15 @@ func TestReadOnlyClientDoesNotCallCreate(t *testing.T) {
 	assert.Empty(t, namespaces.Items)
 }
 
+func TestReadOnlyClientReturnsReadOnlyError(t *testing.T) {
+	cl := k8s.ReadOnlyClient(fake.NewClientBuilder().Build())
+	err := cl.Status().Patch(context.Background(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}}, client.MergeFrom(&v1.Pod{}))
+	readOnlyErr := &k8s.ReadOnlyError{}
+	require.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &readOnlyErr))
+	assert.Equal(t, "Patch", readOnlyErr.Verb)
+	assert.EqualError(t, readOnlyErr, "Patch not allowed in read-only mode")
+}
+
 func TestReadOnlyClientDoesNotCallUpdate(t *testing.T) {
 	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
 
@@ -152,7 +166,7 @@ func TestReadOnlySubResourceClientDoesNotCallCreate(t *testing.T) {
 	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}}
 	cl := k8s.ReadOnlyClient(fake.NewClientBuilder().WithObjects(pod).Build())
 
-	assert.Error(t, cl.Status().Create(context.Background(), pod, &v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}}))
+	assert.EqualError(t, cl.Status().Create(context.Background(), pod, &v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}}), "Create not allowed in read-only mode")
 
 	assert.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(pod), pod))
 	assert.Empty(t, pod.Status.Phase)
@@ -163,7 +177,7 @@ func TestReadOnlySubResourceClientDoesNotCallUpdate(t *testing.T) {
 	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}}
 	cl := k8s.ReadOnlyClient(fake.NewClientBuilder().WithObjects(pod).Build())
 
-	assert.Error(t, cl.Status().Update(context.Background(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}, Status: v1.PodStatus{Phase: v1.PodRunning}}))
+	assert.EqualError(t, cl.Status().Update(context.Background(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}, Status: v1.PodStatus{Phase: v1.PodRunning}}), "Update not allowed in read-only mode")
 
 	assert.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(pod), pod))
 	assert.Empty(t, pod.Status.Phase)
@@ -173,8 +187,120 @@ func TestReadOnlySubResourceClientDoesNotCallPatch(t *testing.T) {
 	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}}
 	cl := k8s.ReadOnlyClient(fake.NewClientBuilder().WithObjects(pod).Build())
 
-	assert.Error(t, cl.Status().Patch(context.Background(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}, Status: v1.PodStatus{Phase: v1.PodRunning}}, client.MergeFrom(pod)))
+	assert.EqualError(t, cl.Status().Patch(context.Background(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}, Status: v1.PodStatus{Phase: v1.PodRunning}}, client.MergeFrom(pod)), "Patch not allowed in read-only mode")
 
 	assert.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(pod), pod))
 	assert.Empty(t, pod.Status.Phase)
 }
+
+func TestReadOnlyClientWithAllowedVerbs(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	cl := k8s.ReadOnlyClient(fake.NewClientBuilder().WithObjects(ns).Build(), k8s.WithAllowedVerbs("Create", "Update"))
+
+	require.NoError(t, cl.Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "created"}}))
+
+	err := cl.Delete(context.Background(), ns)
+	assert.EqualError(t, err, "Delete not allowed in read-only mode")
+	err = cl.DeleteAllOf(context.Background(), ns)
+	assert.EqualError(t, err, "DeleteAllOf not allowed in read-only mode")
+
+	namespaces := &v1.NamespaceList{}
+	require.NoError(t, cl.List(context.Background(), namespaces))
+	require.Len(t, namespaces.Items, 2)
+	assert.Equal(t, "created", namespaces.Items[0].Name)
+	assert.Equal(t, "test", namespaces.Items[1].Name)
+}
+
+func TestReadOnlyClientWithWritableNamespaces(t *testing.T) {
+	sandbox := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "sandbox"}}
+	production := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "production"}}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sandbox"}}
+	cl := k8s.ReadOnlyClient(fake.NewClientBuilder().WithObjects(ns).Build(), k8s.WithWritableNamespaces("sandbox"))
+
+	t.Run("writes in an allowed namespace are delegated", func(t *testing.T) {
+		require.NoError(t, cl.Create(context.Background(), sandbox))
+		assert.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(sandbox), &v1.ConfigMap{}))
+		assert.NoError(t, cl.DeleteAllOf(context.Background(), &v1.ConfigMap{}, client.InNamespace("sandbox")))
+	})
+	t.Run("writes in other namespaces are blocked", func(t *testing.T) {
+		assert.EqualError(t, cl.Create(context.Background(), production), "Create not allowed in read-only mode")
+		assert.EqualError(t, cl.DeleteAllOf(context.Background(), &v1.ConfigMap{}, client.InNamespace("production")), "DeleteAllOf not allowed in read-only mode")
+		assert.EqualError(t, cl.DeleteAllOf(context.Background(), &v1.ConfigMap{}), "DeleteAllOf not allowed in read-only mode")
+	})
+	t.Run("writes to cluster-scoped objects are blocked", func(t *testing.T) {
+		assert.EqualError(t, cl.Delete(context.Background(), ns), "Delete not allowed in read-only mode")
+		assert.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(ns), &v1.Namespace{}))
+	})
+}
+
+func TestReadOnlyClientWithAllowedVerbsAndWritableNamespaces(t *testing.T) {
+	sandbox := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "sandbox"}}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sandbox"}}
+	cl := k8s.ReadOnlyClient(
+		fake.NewClientBuilder().WithObjects(ns).Build(),
+		k8s.WithAllowedVerbs("Create"),
+		k8s.WithWritableNamespaces("sandbox"),
+	)
+
+	t.Run("allowed verbs in a writable namespace are delegated", func(t *testing.T) {
+		require.NoError(t, cl.Create(context.Background(), sandbox))
+		assert.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(sandbox), &v1.ConfigMap{}))
+	})
+	t.Run("other verbs in a writable namespace are blocked", func(t *testing.T) {
+		assert.EqualError(t, cl.Delete(context.Background(), sandbox), "Delete not allowed in read-only mode")
+		assert.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(sandbox), &v1.ConfigMap{}))
+	})
+	t.Run("allowed verbs in other namespaces are blocked", func(t *testing.T) {
+		production := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "production"}}
+		assert.EqualError(t, cl.Create(context.Background(), production), "Create not allowed in read-only mode")
+	})
+	t.Run("allowed verbs on cluster-scoped objects are blocked", func(t *testing.T) {
+		created := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "created"}}
+		assert.EqualError(t, cl.Create(context.Background(), created), "Create not allowed in read-only mode")
+		assert.Error(t, cl.Get(context.Background(), client.ObjectKeyFromObject(created), &v1.Namespace{}))
+	})
+}
+
+func TestReadOnlyClientBlockedCallback(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	blocked := map[string]int{}
+	cl := k8s.ReadOnlyClient(
+		fake.NewClientBuilder().WithObjects(ns).Build(),
+		k8s.WithBlockedCallback(func(verb string) {
+			blocked[verb]++
+		}),
+	)
+
+	assert.EqualError(t, cl.Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "created"}}), "Create not allowed in read-only mode")
+	assert.EqualError(t, cl.Update(context.Background(), ns), "Update not allowed in read-only mode")
+	assert.EqualError(t, cl.Update(context.Background(), ns), "Update not allowed in read-only mode")
+	assert.EqualError(t, cl.Patch(context.Background(), ns, client.MergeFrom(ns)), "Patch not allowed in read-only mode")
+	assert.EqualError(t, cl.Delete(context.Background(), ns), "Delete not allowed in read-only mode")
+	assert.Error(t, cl.Status().Update(context.Background(), ns))
+	assert.Error(t, cl.Status().Patch(context.Background(), ns, client.MergeFrom(ns)))
+	require.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(ns), &v1.Namespace{}))
+
+	assert.Equal(t, map[string]int{"Create": 1, "Update": 3, "Patch": 2, "Delete": 1}, blocked)
+}
+
+func TestReadOnlyWatchClient(t *testing.T) {
+	inner := fake.NewClientBuilder().Build()
+	cl := k8s.ReadOnlyWatchClient(inner)
+
+	w, err := cl.Watch(context.Background(), &v1.NamespaceList{})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	require.NoError(t, inner.Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "watched"}}))
+	select {
+	case event := <-w.ResultChan():
+		assert.Equal(t, watch.Added, event.Type)
+		require.IsType(t, &v1.Namespace{}, event.Object)
+		assert.Equal(t, "watched", event.Object.(*v1.Namespace).Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watch event")
+	}
+
+	assert.EqualError(t, cl.Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "created"}}), "Create not allowed in read-only mode")
+	assert.NoError(t, cl.Get(context.Background(), client.ObjectKey{Name: "watched"}, &v1.Namespace{}))
+}
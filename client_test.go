@@ -1,11 +1,24 @@
 package k8s_test
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	k8s "github.com/adevinta/go-k8s-toolkit"
 	system "github.com/adevinta/go-system-toolkit"
@@ -14,6 +27,15 @@ import (
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/transport"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
 func TestKubeConfigPath(t *testing.T) {
@@ -34,9 +56,90 @@ func TestKubeConfigPath(t *testing.T) {
 	assert.Equal(t, "test-data/home/.kube/config", k8s.KubeConfigPath("./test-data/home/.kube/config"))
 }
 
+func TestKubeConfigPathPrecedence(t *testing.T) {
+	t.Cleanup(system.Reset)
+	dir := t.TempDir()
+	explicitPath := filepath.Join(dir, "explicit")
+	envPath := filepath.Join(dir, "env")
+	homePath := filepath.Join(dir, "home", ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(homePath), 0755))
+	for _, path := range []string{explicitPath, envPath, homePath} {
+		writeKubeConfigWithoutAuth(t, path)
+	}
+	t.Setenv("KUBECONFIG", envPath)
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+
+	t.Run("the explicit path wins", func(t *testing.T) {
+		assert.Equal(t, explicitPath, k8s.KubeConfigPath(explicitPath))
+	})
+	t.Run("a non-existent explicit path falls back to KUBECONFIG", func(t *testing.T) {
+		assert.Equal(t, envPath, k8s.KubeConfigPath(filepath.Join(dir, "does-not-exist")))
+	})
+	t.Run("KUBECONFIG wins over HOME", func(t *testing.T) {
+		assert.Equal(t, envPath, k8s.KubeConfigPath(""))
+	})
+	t.Run("a non-existent KUBECONFIG falls back to HOME", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", filepath.Join(dir, "does-not-exist"))
+		assert.Equal(t, homePath, k8s.KubeConfigPath(""))
+	})
+	t.Run("the first existing entry of a KUBECONFIG list is used", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", strings.Join([]string{"/nonexistent", envPath, explicitPath}, string(filepath.ListSeparator)))
+		assert.Equal(t, envPath, k8s.KubeConfigPath(""))
+	})
+	t.Run("an empty KUBECONFIG falls back to HOME", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "")
+		assert.Equal(t, homePath, k8s.KubeConfigPath(""))
+	})
+	t.Run("when no file exists an empty path is returned", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "")
+		t.Setenv("HOME", filepath.Join(dir, "no-home"))
+		assert.Equal(t, "", k8s.KubeConfigPath(filepath.Join(dir, "does-not-exist")))
+	})
+}
+
+func TestClone(t *testing.T) {
+	base := k8s.NewClientConfigBuilder().
+		WithKubeConfigPaths("./test-data/home/.kube/config").
+		WithImpersonateExtra("team", "a")
+	clone := base.Clone()
+	clone.ClientConfigLoadingRules.Precedence[0] = "./does-not-exist"
+	clone = clone.WithContext("kind-chart-test").
+		WithImpersonateUserName("someone").
+		WithImpersonateExtra("team", "b")
+
+	assert.Equal(t, []string{"./test-data/home/.kube/config"}, base.ClientConfigLoadingRules.Precedence)
+	assert.Empty(t, base.ConfigOverrides.CurrentContext)
+	assert.Empty(t, base.ConfigOverrides.AuthInfo.Impersonate)
+	assert.Equal(t, map[string][]string{"team": {"a"}}, base.ConfigOverrides.AuthInfo.ImpersonateUserExtra)
+	assert.Equal(t, "kind-chart-test", clone.ConfigOverrides.CurrentContext)
+	assert.Equal(t, map[string][]string{"team": {"a", "b"}}, clone.ConfigOverrides.AuthInfo.ImpersonateUserExtra)
+
+	context, err := base.CurrentContext()
+	require.NoError(t, err)
+	assert.Equal(t, "kind-chart-test-v1.14.10", context)
+}
+
+func TestBuilderMethodsDoNotModifyTheReceiver(t *testing.T) {
+	base := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithImpersonateUserName("someone")
+	first := base.WithContext("kind-chart-test").WithImpersonateExtra("team", "a")
+	second := base.WithContext("kind-chart-test-v1.14.10").WithImpersonateExtra("team", "b")
+
+	firstConfig, err := first.Build()
+	require.NoError(t, err)
+	secondConfig, err := second.Build()
+	require.NoError(t, err)
+	assert.Equal(t, "https://127.0.0.1:54148", firstConfig.Host)
+	assert.Equal(t, "https://127.0.0.1:49613", secondConfig.Host)
+	assert.Equal(t, map[string][]string{"team": {"a"}}, first.ConfigOverrides.AuthInfo.ImpersonateUserExtra)
+	assert.Equal(t, map[string][]string{"team": {"b"}}, second.ConfigOverrides.AuthInfo.ImpersonateUserExtra)
+	assert.Empty(t, base.ConfigOverrides.CurrentContext)
+	assert.Empty(t, base.ConfigOverrides.AuthInfo.ImpersonateUserExtra)
+	assert.Equal(t, "./test-data/home/.kube/config", base.ClientConfigLoadingRules.ExplicitPath)
+}
+
 func TestImpersonateUserName(t *testing.T) {
 	builder := k8s.NewClientConfigBuilder()
-	builder.WithKubeConfigPath("./test-data/home/.kube/config")
+	builder = builder.WithKubeConfigPath("./test-data/home/.kube/config")
 	builder = builder.WithImpersonateUserName("test-user")
 	config, err := builder.Build()
 	assert.NoError(t, err)
@@ -47,14 +150,14 @@ func TestImpersonateUserName(t *testing.T) {
 func TestImpersonateGroups(t *testing.T) {
 	t.Run("without impersonate username an error is returned", func(t *testing.T) {
 		builder := k8s.NewClientConfigBuilder()
-		builder.WithKubeConfigPath("./test-data/home/.kube/config")
+		builder = builder.WithKubeConfigPath("./test-data/home/.kube/config")
 		builder = builder.WithImpersonateUserGroups("test-group", "test-groups-2")
 		_, err := builder.Build()
 		assert.Errorf(t, err, "impersonate group without a user should be reported as an error. Kubernetes does not allow it")
 	})
 	t.Run("with impersonate groups is configured", func(t *testing.T) {
 		builder := k8s.NewClientConfigBuilder()
-		builder.WithKubeConfigPath("./test-data/home/.kube/config")
+		builder = builder.WithKubeConfigPath("./test-data/home/.kube/config")
 		builder = builder.WithImpersonateUserName("test-user")
 		builder = builder.WithImpersonateUserGroups("test-group", "test-groups-2")
 		_, err := builder.Build()
@@ -63,6 +166,31 @@ func TestImpersonateGroups(t *testing.T) {
 	})
 }
 
+func TestImpersonateExtra(t *testing.T) {
+	t.Run("without impersonate username an error is returned", func(t *testing.T) {
+		_, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithImpersonateExtra("scopes", "view").
+			Build()
+		assert.Errorf(t, err, "impersonate extra without a user should be reported as an error. Kubernetes does not allow it")
+	})
+	t.Run("with impersonate extra is configured", func(t *testing.T) {
+		config, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithImpersonateUserName("test-user").
+			WithImpersonateExtra("scopes", "view", "edit").
+			WithImpersonateExtra("scopes", "admin").
+			WithImpersonateExtra("reason", "testing").
+			Build()
+		require.NoError(t, err)
+		require.NotNil(t, config)
+		assert.Equal(t, map[string][]string{
+			"scopes": {"view", "edit", "admin"},
+			"reason": {"testing"},
+		}, config.Impersonate.Extra)
+	})
+}
+
 func TestClientConfigBuilder(t *testing.T) {
 	t.Run("When not in github actions", func(t *testing.T) {
 		t.Run("When a kubeconfig is available", func(t *testing.T) {
@@ -128,3 +256,695 @@ func TestClientConfigBuilder(t *testing.T) {
 		})
 	})
 }
+
+func TestBuildDynamicClient(t *testing.T) {
+	t.Run("with a valid kubeconfig a dynamic client is returned", func(t *testing.T) {
+		client, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").BuildDynamicClient()
+		require.NoError(t, err)
+		assert.NotNil(t, client)
+	})
+	t.Run("when the config can not be built the error is returned", func(t *testing.T) {
+		client, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithImpersonateUserGroups("test-group").
+			BuildDynamicClient()
+		assert.Error(t, err)
+		assert.Nil(t, client)
+	})
+}
+
+func TestBuildDiscoveryClient(t *testing.T) {
+	t.Run("with a valid config a discovery client is returned", func(t *testing.T) {
+		client, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").BuildDiscoveryClient()
+		require.NoError(t, err)
+		assert.NotNil(t, client)
+	})
+	t.Run("when the config can not be built the error is returned", func(t *testing.T) {
+		client, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithImpersonateUserGroups("test-group").
+			BuildDiscoveryClient()
+		assert.Error(t, err)
+		assert.Nil(t, client)
+	})
+}
+
+// newDiscoveryServer starts a fake API server serving discovery documents for core resources, and the
+// given extra responses, keyed by path.
+func newDiscoveryServer(t *testing.T, extra ...map[string]interface{}) *httptest.Server {
+	t.Helper()
+	responses := map[string]interface{}{
+		"/api": metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{"v1"},
+		},
+		"/apis": metav1.APIGroupList{
+			TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+		},
+		"/api/v1": metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get", "list"}},
+				{Name: "namespaces", SingularName: "namespace", Namespaced: false, Kind: "Namespace", Verbs: metav1.Verbs{"get", "list"}},
+				{Name: "nodes", SingularName: "node", Namespaced: false, Kind: "Node", Verbs: metav1.Verbs{"get", "list"}},
+			},
+		},
+		"/version": version.Info{
+			Major:      "1",
+			Minor:      "29",
+			GitVersion: "v1.29.0",
+		},
+	}
+	for _, e := range extra {
+		for path, response := range e {
+			responses[path] = response
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBuildRESTMapper(t *testing.T) {
+	server := newDiscoveryServer(t)
+	mapper, err := k8s.NewClientConfigBuilder().
+		WithKubeConfigPath("./test-data/home/.kube/config").
+		WithServerURL(server.URL).
+		BuildRESTMapper()
+	require.NoError(t, err)
+	require.NotNil(t, mapper)
+
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Kind: "Pod"}, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "pods"}, mapping.Resource)
+}
+
+func TestWithKubeConfigPaths(t *testing.T) {
+	t.Cleanup(system.Reset)
+	dir := t.TempDir()
+	clustersPath := filepath.Join(dir, "clusters")
+	contextsPath := filepath.Join(dir, "contexts")
+
+	testutils.EnsureYAMLFileContent(t, system.DefaultFileSystem, clustersPath, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Config",
+		"users": []interface{}{
+			map[string]interface{}{
+				"name": "user-name",
+				"user": map[string]string{
+					"token": "k8s-token",
+				},
+			},
+		},
+		"clusters": []interface{}{
+			map[string]interface{}{
+				"name": "cluster-name",
+				"cluster": map[string]string{
+					"server": "https://k8s.tld",
+				},
+			},
+		},
+	})
+	testutils.EnsureYAMLFileContent(t, system.DefaultFileSystem, contextsPath, map[string]interface{}{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"current-context": "test",
+		"contexts": []interface{}{
+			map[string]interface{}{
+				"name": "test",
+				"context": map[string]string{
+					"cluster": "cluster-name",
+					"user":    "user-name",
+				},
+			},
+		},
+	})
+
+	cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPaths(clustersPath, contextsPath).Build()
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	assert.Equal(t, "https://k8s.tld", cfg.Host)
+	assert.Equal(t, "k8s-token", cfg.BearerToken)
+}
+
+func TestWithContext(t *testing.T) {
+	t.Run("when the context exists it is used", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithContext("kind-chart-test").
+			Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, "https://127.0.0.1:54148", cfg.Host)
+	})
+	t.Run("when the context does not exist an error is returned", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithContext("foo").
+			Build()
+		assert.EqualError(t, err, `context "foo" not found`)
+		assert.Nil(t, cfg)
+	})
+}
+
+func TestNamespace(t *testing.T) {
+	t.Cleanup(system.Reset)
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	testutils.EnsureYAMLFileContent(t, system.DefaultFileSystem, kubeconfigPath, map[string]interface{}{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"current-context": "with-namespace",
+		"contexts": []interface{}{
+			map[string]interface{}{
+				"name": "with-namespace",
+				"context": map[string]string{
+					"cluster":   "cluster-name",
+					"namespace": "my-namespace",
+				},
+			},
+			map[string]interface{}{
+				"name": "without-namespace",
+				"context": map[string]string{
+					"cluster": "cluster-name",
+				},
+			},
+		},
+		"clusters": []interface{}{
+			map[string]interface{}{
+				"name": "cluster-name",
+				"cluster": map[string]string{
+					"server": "https://k8s.tld",
+				},
+			},
+		},
+	})
+
+	t.Run("when the context declares a namespace it is returned", func(t *testing.T) {
+		namespace, err := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).Namespace()
+		require.NoError(t, err)
+		assert.Equal(t, "my-namespace", namespace)
+	})
+	t.Run("when the context does not declare a namespace the default one is returned", func(t *testing.T) {
+		namespace, err := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).WithContext("without-namespace").Namespace()
+		require.NoError(t, err)
+		assert.Equal(t, "default", namespace)
+	})
+	t.Run("when the context does not exist an error is returned", func(t *testing.T) {
+		_, err := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).WithContext("foo").Namespace()
+		assert.Error(t, err)
+	})
+	t.Run("when the context declares a namespace the default namespace override is ignored", func(t *testing.T) {
+		namespace, err := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).WithDefaultNamespace("home").Namespace()
+		require.NoError(t, err)
+		assert.Equal(t, "my-namespace", namespace)
+	})
+	t.Run("when the context does not declare a namespace the default namespace override is returned", func(t *testing.T) {
+		builder := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).WithContext("without-namespace").WithDefaultNamespace("home")
+		namespace, err := builder.Namespace()
+		require.NoError(t, err)
+		assert.Equal(t, "home", namespace)
+
+		cfg, err := builder.Build()
+		require.NoError(t, err)
+		assert.Equal(t, "https://k8s.tld", cfg.Host)
+	})
+}
+
+func TestContexts(t *testing.T) {
+	t.Run("the contexts of the kubeconfig are returned sorted", func(t *testing.T) {
+		contexts, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").Contexts()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"kind-chart-test", "kind-chart-test-v1.14.10"}, contexts)
+	})
+	t.Run("the contexts of all the kubeconfig files are returned", func(t *testing.T) {
+		t.Cleanup(system.Reset)
+		otherPath := filepath.Join(t.TempDir(), "config")
+		writeKubeConfigWithoutAuth(t, otherPath)
+		contexts, err := k8s.NewClientConfigBuilder().WithKubeConfigPaths("./test-data/home/.kube/config", otherPath).Contexts()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"kind-chart-test", "kind-chart-test-v1.14.10", "test"}, contexts)
+	})
+	t.Run("when the selected context does not exist an error is returned", func(t *testing.T) {
+		_, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithContext("foo").Contexts()
+		assert.EqualError(t, err, `context "foo" not found`)
+	})
+}
+
+func TestCurrentContext(t *testing.T) {
+	t.Run("without override the kubeconfig current context is returned", func(t *testing.T) {
+		context, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").CurrentContext()
+		require.NoError(t, err)
+		assert.Equal(t, "kind-chart-test-v1.14.10", context)
+	})
+	t.Run("with an override the selected context is returned", func(t *testing.T) {
+		context, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithContext("kind-chart-test").CurrentContext()
+		require.NoError(t, err)
+		assert.Equal(t, "kind-chart-test", context)
+	})
+	t.Run("when the selected context does not exist an error is returned", func(t *testing.T) {
+		_, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithContext("foo").CurrentContext()
+		assert.EqualError(t, err, `context "foo" not found`)
+	})
+}
+
+func writeKubeConfigWithoutAuth(t *testing.T, path string) {
+	t.Helper()
+	testutils.EnsureYAMLFileContent(t, system.DefaultFileSystem, path, map[string]interface{}{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"current-context": "test",
+		"contexts": []interface{}{
+			map[string]interface{}{
+				"name": "test",
+				"context": map[string]string{
+					"cluster": "cluster-name",
+				},
+			},
+		},
+		"clusters": []interface{}{
+			map[string]interface{}{
+				"name": "cluster-name",
+				"cluster": map[string]string{
+					"server": "https://k8s.tld",
+				},
+			},
+		},
+	})
+}
+
+func TestWithBearerToken(t *testing.T) {
+	t.Cleanup(system.Reset)
+	dir := t.TempDir()
+	kubeconfigPath := filepath.Join(dir, "config")
+	writeKubeConfigWithoutAuth(t, kubeconfigPath)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("file-token"), 0600))
+	os.Setenv("KUBECONFIG", kubeconfigPath)
+
+	t.Run("without bearer token the token file is used", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithTokenFile("token").Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, "file-token", cfg.BearerToken)
+	})
+	t.Run("with bearer token the token file is ignored", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithTokenFile("token").WithBearerToken("injected-token").Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, "injected-token", cfg.BearerToken)
+	})
+}
+
+func TestTokenFileNextToExplicitKubeConfig(t *testing.T) {
+	t.Cleanup(system.Reset)
+	os.Unsetenv("KUBECONFIG")
+	os.Setenv("HOME", "./no-home")
+	dir := t.TempDir()
+	kubeconfigPath := filepath.Join(dir, "config")
+	writeKubeConfigWithoutAuth(t, kubeconfigPath)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("file-token"), 0600))
+
+	cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).WithTokenFile("token").Build()
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	assert.Equal(t, "file-token", cfg.BearerToken)
+}
+
+func TestMissingTokenFile(t *testing.T) {
+	t.Cleanup(system.Reset)
+	os.Unsetenv("KUBECONFIG")
+	os.Setenv("HOME", "./no-home")
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	writeKubeConfigWithoutAuth(t, kubeconfigPath)
+
+	t.Run("when the token file is explicitly requested an error is returned", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).WithTokenFile("token").Build()
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.ErrorContains(t, err, fmt.Sprintf("reading token file %q next to kubeconfig %q", filepath.Join(filepath.Dir(kubeconfigPath), "token"), kubeconfigPath))
+		assert.Nil(t, cfg)
+	})
+	t.Run("when the kubeconfig is not loaded from a file an error is returned", func(t *testing.T) {
+		kubeconfig, err := os.ReadFile(kubeconfigPath)
+		require.NoError(t, err)
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigBytes(kubeconfig).WithTokenFile("token").Build()
+		assert.ErrorContains(t, err, `token file "token" can not be located`)
+		assert.Nil(t, cfg)
+	})
+	t.Run("when no token file is requested the config is returned without authentication", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Empty(t, cfg.BearerToken)
+	})
+}
+
+func TestContentType(t *testing.T) {
+	t.Run("by default the content type is left to client-go", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Empty(t, cfg.ContentType)
+		assert.Empty(t, cfg.AcceptContentTypes)
+	})
+	t.Run("with content type it is propagated to the config", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithContentType("application/json").Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, "application/json", cfg.ContentType)
+		assert.Equal(t, "application/json", cfg.AcceptContentTypes)
+	})
+	t.Run("with protobuf JSON is still accepted", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithProtobuf().Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, "application/vnd.kubernetes.protobuf", cfg.ContentType)
+		assert.Equal(t, "application/vnd.kubernetes.protobuf,application/json", cfg.AcceptContentTypes)
+	})
+}
+
+func TestWithInClusterConfig(t *testing.T) {
+	t.Run("when not running in a pod an error is returned", func(t *testing.T) {
+		t.Cleanup(system.Reset)
+		t.Setenv("KUBERNETES_SERVICE_HOST", "")
+		t.Setenv("KUBERNETES_SERVICE_PORT", "")
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithInClusterConfig().Build()
+		assert.ErrorIs(t, err, rest.ErrNotInCluster)
+		assert.Nil(t, cfg)
+	})
+	t.Run("when the service account environment is present the kubeconfig is ignored", func(t *testing.T) {
+		t.Cleanup(system.Reset)
+		t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+		t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithInClusterConfig().Build()
+		if _, statErr := os.Stat("/var/run/secrets/kubernetes.io/serviceaccount/token"); statErr != nil {
+			// Not running in a pod, the service account token can't be found
+			assert.ErrorIs(t, err, os.ErrNotExist)
+			assert.Nil(t, cfg)
+			return
+		}
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, "https://10.0.0.1:443", cfg.Host)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("when the API answers no error is returned", func(t *testing.T) {
+		server := newDiscoveryServer(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithServerURL(server.URL).
+			Validate(ctx)
+		assert.NoError(t, err)
+	})
+	t.Run("when the API refuses connections an error is returned", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithServerURL(server.URL).
+			Validate(ctx)
+		assert.ErrorContains(t, err, "unable to reach the Kubernetes API")
+	})
+	t.Run("when the context is cancelled the API is not waited for", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithServerURL(server.URL).
+			Validate(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("when the context is cancelled while waiting an error is returned", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithServerURL(server.URL).
+			Validate(ctx)
+		assert.ErrorContains(t, err, "unable to reach the Kubernetes API")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestWithServerURL(t *testing.T) {
+	t.Run("a bare host is rejected", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithServerURL("k8s.tld").Build()
+		assert.ErrorContains(t, err, `invalid server URL "k8s.tld"`)
+		assert.Nil(t, cfg)
+	})
+	t.Run("a non http scheme is rejected", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithServerURL("ftp://k8s.tld").Build()
+		assert.ErrorContains(t, err, `invalid server URL "ftp://k8s.tld"`)
+		assert.Nil(t, cfg)
+	})
+	t.Run("an https URL is used", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").WithServerURL("https://k8s.tld").Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, "https://k8s.tld", cfg.Host)
+	})
+}
+
+func TestWithExecProvider(t *testing.T) {
+	exec := &clientcmdapi.ExecConfig{
+		Command:         "token-helper",
+		Args:            []string{"get-token"},
+		APIVersion:      "client.authentication.k8s.io/v1",
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+	}
+	t.Run("the exec provider is configured", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithImpersonateUserName("test-user").
+			WithExecProvider(exec).
+			Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		require.NotNil(t, cfg.ExecProvider)
+		assert.Equal(t, "token-helper", cfg.ExecProvider.Command)
+		assert.Equal(t, []string{"get-token"}, cfg.ExecProvider.Args)
+		assert.Equal(t, "test-user", cfg.Impersonate.UserName)
+	})
+	t.Run("combined with a bearer token an error is returned", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithBearerToken("token").
+			WithExecProvider(exec).
+			Build()
+		assert.Error(t, err)
+		assert.Nil(t, cfg)
+	})
+}
+
+func TestWithBasicAuth(t *testing.T) {
+	t.Cleanup(system.Reset)
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	writeKubeConfigWithoutAuth(t, kubeconfigPath)
+
+	t.Run("the username and password are configured", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath(kubeconfigPath).
+			WithBasicAuth("admin", "secret").
+			Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, "admin", cfg.Username)
+		assert.Equal(t, "secret", cfg.Password)
+		assert.Empty(t, cfg.BearerToken)
+	})
+	t.Run("combined with a bearer token an error is returned", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath(kubeconfigPath).
+			WithBearerToken("token").
+			WithBasicAuth("admin", "secret").
+			Build()
+		assert.EqualError(t, err, "bearer token and basic authentication can not be used together")
+		assert.Nil(t, cfg)
+	})
+}
+
+func newClientCertificate(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-user"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	t.Cleanup(system.Reset)
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	writeKubeConfigWithoutAuth(t, kubeconfigPath)
+	certData, keyData := newClientCertificate(t)
+
+	t.Run("a valid key pair is configured", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath(kubeconfigPath).
+			WithClientCertificate(certData, keyData).
+			Build()
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, certData, cfg.TLSClientConfig.CertData)
+		assert.Equal(t, keyData, cfg.TLSClientConfig.KeyData)
+	})
+	t.Run("an invalid key pair returns an error", func(t *testing.T) {
+		_, otherKeyData := newClientCertificate(t)
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath(kubeconfigPath).
+			WithClientCertificate(certData, otherKeyData).
+			Build()
+		assert.ErrorContains(t, err, "invalid client certificate")
+		assert.Nil(t, cfg)
+	})
+}
+
+func TestWithTLSServerName(t *testing.T) {
+	cfg, err := k8s.NewClientConfigBuilder().
+		WithKubeConfigPath("./test-data/home/.kube/config").
+		WithTLSServerName("kubernetes.default.svc").
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, "kubernetes.default.svc", cfg.TLSClientConfig.ServerName)
+
+	cfg, err = k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").Build()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.TLSClientConfig.ServerName)
+}
+
+func TestWithRateLimiter(t *testing.T) {
+	rateLimiter := flowcontrol.NewFakeAlwaysRateLimiter()
+	cfg, err := k8s.NewClientConfigBuilder().
+		WithKubeConfigPath("./test-data/home/.kube/config").
+		WithRateLimiter(rateLimiter).
+		Build()
+	require.NoError(t, err)
+	assert.Same(t, rateLimiter, cfg.RateLimiter)
+
+	cfg, err = k8s.NewClientConfigBuilder().WithKubeConfigPath("./test-data/home/.kube/config").Build()
+	require.NoError(t, err)
+	assert.Nil(t, cfg.RateLimiter)
+}
+
+func TestWithTransportWrapper(t *testing.T) {
+	t.Cleanup(system.Reset)
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	writeKubeConfigWithoutAuth(t, kubeconfigPath)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	calls := []string{}
+	recording := func(name string) transport.WrapperFunc {
+		return func(rt http.RoundTripper) http.RoundTripper {
+			return testutils.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return rt.RoundTrip(r)
+			})
+		}
+	}
+	base := k8s.NewClientConfigBuilder().WithKubeConfigPath(kubeconfigPath).WithServerURL(server.URL)
+	cfg, err := base.
+		WithTransportWrapper(recording("first")).
+		WithTransportWrapper(recording("second")).
+		Build()
+	require.NoError(t, err)
+
+	httpClient, err := rest.HTTPClientFor(cfg)
+	require.NoError(t, err)
+	resp, err := httpClient.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"second", "first"}, calls)
+
+	cfg, err = base.Build()
+	require.NoError(t, err)
+	assert.Nil(t, cfg.WrapTransport)
+}
+
+func TestBuildManager(t *testing.T) {
+	server := newDiscoveryServer(t)
+	scheme := runtime.NewScheme()
+	mgr, err := k8s.NewClientConfigBuilder().
+		WithKubeConfigPath("./test-data/home/.kube/config").
+		WithServerURL(server.URL).
+		BuildManager(scheme, manager.Options{})
+	require.NoError(t, err)
+	require.NotNil(t, mgr)
+	assert.Same(t, scheme, mgr.GetScheme())
+	assert.Equal(t, server.URL, mgr.GetConfig().Host)
+
+	t.Run("when the configuration is invalid", func(t *testing.T) {
+		mgr, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigPath("./test-data/home/.kube/config").
+			WithServerURL("not-a-url").
+			BuildManager(scheme, manager.Options{})
+		assert.Error(t, err)
+		assert.Nil(t, mgr)
+	})
+}
+
+func TestWithKubeConfigBytes(t *testing.T) {
+	t.Cleanup(system.Reset)
+	kubeConfig, err := os.ReadFile("./test-data/home/.kube/config")
+	require.NoError(t, err)
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", "./no-home")
+
+	cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigBytes(kubeConfig).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "https://127.0.0.1:49613", cfg.Host)
+	assert.NotEmpty(t, cfg.TLSClientConfig.CertData)
+
+	t.Run("overrides still apply", func(t *testing.T) {
+		cfg, err := k8s.NewClientConfigBuilder().
+			WithKubeConfigBytes(kubeConfig).
+			WithContext("kind-chart-test").
+			WithImpersonateUserName("my-user").
+			Build()
+		require.NoError(t, err)
+		assert.Equal(t, "https://127.0.0.1:54148", cfg.Host)
+		assert.Equal(t, "my-user", cfg.Impersonate.UserName)
+	})
+
+	t.Run("unknown contexts are rejected", func(t *testing.T) {
+		_, err := k8s.NewClientConfigBuilder().WithKubeConfigBytes(kubeConfig).WithContext("unknown").Build()
+		assert.EqualError(t, err, `context "unknown" not found`)
+	})
+
+	t.Run("invalid content is rejected", func(t *testing.T) {
+		_, err := k8s.NewClientConfigBuilder().WithKubeConfigBytes([]byte("clusters: [")).Build()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,18 @@
+// Package k8stesting provides test doubles for the go-k8s-toolkit types.
+package k8stesting
+
+import (
+	k8s "github.com/adevinta/go-k8s-toolkit"
+)
+
+// FakeCluster is a KubeCluster whose kubeconfig path is configured up front.
+type FakeCluster struct {
+	Path string
+}
+
+var _ k8s.KubeCluster = FakeCluster{}
+
+// KubeConfigPath returns the configured kubeconfig path.
+func (c FakeCluster) KubeConfigPath() string {
+	return c.Path
+}
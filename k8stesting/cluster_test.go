@@ -0,0 +1,15 @@
+package k8stesting_test
+
+import (
+	"testing"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/adevinta/go-k8s-toolkit/k8stesting"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeCluster(t *testing.T) {
+	var cluster k8s.KubeCluster = k8stesting.FakeCluster{Path: "./test-data/kubeconfig"}
+	assert.Equal(t, "./test-data/kubeconfig", cluster.KubeConfigPath())
+	assert.Empty(t, k8stesting.FakeCluster{}.KubeConfigPath())
+}
@@ -3,30 +3,127 @@ package k8s
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const DefaultVersion = "v0.11.1"
 
+// ReuseEnv is the environment variable that, when set to a true value like 1, makes Start reuse an existing
+// cluster with a valid kubeconfig without waiting for it to be ready.
+const ReuseEnv = "KIND_REUSE"
+
+// DefaultInstallRetries is the number of download retries used when KinD.InstallRetries is zero.
+const DefaultInstallRetries = 3
+
+func reuseEnabled() bool {
+	reuse, _ := strconv.ParseBool(os.Getenv(ReuseEnv))
+	return reuse
+}
+
 type KubeCluster interface {
 	KubeConfigPath() string
 }
 
+// CommandRunner runs the kind commands.
+type CommandRunner interface {
+	// Run runs the command name with args, writing its standard and error outputs to stdout and stderr.
+	// env holds environment variables, in the key=value form, to add to the current environment of the command,
+	// like KIND_EXPERIMENTAL_PROVIDER.
+	Run(ctx context.Context, env []string, stdout, stderr io.Writer, name string, args ...string) error
+}
+
+// ExecCommandRunner is the CommandRunner running commands with os/exec.
+type ExecCommandRunner struct {
+	// Env holds environment variables, in the key=value form, added to the current environment of the commands.
+	Env []string
+}
+
+func (r ExecCommandRunner) Run(ctx context.Context, env []string, stdout, stderr io.Writer, name string, args ...string) error {
+	c := exec.CommandContext(ctx, name, args...)
+	c.Stdout = stdout
+	c.Stderr = stderr
+	if len(r.Env) > 0 || len(env) > 0 {
+		c.Env = append(append(os.Environ(), r.Env...), env...)
+	}
+	return c.Run()
+}
+
 type KinD struct {
 	Dir     string
 	Version string
+	// Runner runs the kind commands. Defaults to ExecCommandRunner when nil.
+	Runner CommandRunner
+	// HTTPClient downloads the kind binary. Defaults to http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// PreferSystemBinary makes the kind binary found in the PATH be used instead of downloading one,
+	// provided it reports the expected Version.
+	PreferSystemBinary bool
+	// LookPath finds the kind binary in the PATH. Defaults to exec.LookPath when nil.
+	LookPath func(file string) (string, error)
+	// InstallRetries is the number of times the kind binary download is retried after a network failure.
+	// Defaults to DefaultInstallRetries when zero. A negative value disables the retries.
+	InstallRetries int
+	// InstallBackoff is the delay before the first download retry, doubled after each retry.
+	// Defaults to one second when zero.
+	InstallBackoff time.Duration
+	// Checksum holds the expected hex encoded SHA256 of the kind binary, keyed by GOOS/GOARCH, like linux/amd64.
+	Checksum map[string]string
+
+	// MinReadyPods is the number of pods that must exist, all running, for a started cluster to be ready.
+	// When zero, the cluster is ready as soon as all its nodes are.
+	MinReadyPods int
+	// LogsOutput receives the cluster logs printed by Start when the cluster creation fails.
+	// Defaults to os.Stdout when nil.
+	LogsOutput io.Writer
+
+	provider string
+}
+
+// systemBinaries caches, per *KinD, the kind binary resolved in the PATH.
+// It is kept out of KinD so that resolving the binary never writes to a KinD shared between goroutines.
+var systemBinaries sync.Map
+
+type systemBinaryCache struct {
+	lock     sync.Mutex
+	version  string
+	path     string
+	resolved bool
+}
+
+// CreateClusterError is returned by Start when kind fails to create the cluster.
+type CreateClusterError struct {
+	Cluster string
+	// LogsDir is the directory the cluster logs were exported to. It is empty when the export failed.
+	LogsDir string
+	Err     error
+}
+
+func (e *CreateClusterError) Error() string {
+	if e.LogsDir == "" {
+		return fmt.Sprintf("unable to create cluster %s: %v", e.Cluster, e.Err)
+	}
+	return fmt.Sprintf("unable to create cluster %s, logs exported to %s: %v", e.Cluster, e.LogsDir, e.Err)
+}
+
+func (e *CreateClusterError) Unwrap() error {
+	return e.Err
 }
 
 type KinDCluster struct {
@@ -39,24 +136,112 @@ type KinDCluster struct {
 var DefaultKind = KinD{
 	Dir:     "./.kind",
 	Version: DefaultVersion,
+	// kept for backward compatibility with the historical readiness check
+	MinReadyPods: 8,
+}
+
+// WithProvider returns a copy of k using the kind node provider, like podman. Docker is used when unset.
+// The provider is passed to the Runner of the kind commands through KIND_EXPERIMENTAL_PROVIDER.
+func (k *KinD) WithProvider(provider string) *KinD {
+	c := *k
+	c.provider = provider
+	return &c
 }
 
+func (k *KinD) runner() CommandRunner {
+	if k.Runner == nil {
+		return ExecCommandRunner{}
+	}
+	return k.Runner
+}
+
+// run runs a command with the Runner, adding the environment expected by kind.
+func (k *KinD) run(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) error {
+	var env []string
+	if k.provider != "" {
+		env = append(env, "KIND_EXPERIMENTAL_PROVIDER="+k.provider)
+	}
+	return k.runner().Run(ctx, env, stdout, stderr, name, args...)
+}
+
+// ListClusters returns the names of the existing kind clusters.
+// It returns an empty list when the clusters can not be listed, use ListClustersContext to get the error.
 func (k *KinD) ListClusters() []string {
-	c := exec.Command(k.path(), "get", "clusters")
-	b := &bytes.Buffer{}
-	c.Stdout = b
-	c.Stderr = os.Stderr
-	if err := c.Run(); err != nil {
+	clusters, err := k.ListClustersContext(context.Background())
+	if err != nil {
 		return []string{}
 	}
-	r := strings.Split(b.String(), "\n")
-	for i, s := range r {
-		r[i] = strings.Trim(s, " \n")
+	return clusters
+}
+
+// ListClustersContext returns the names of the existing kind clusters.
+// It fails when the kind command fails or ctx is cancelled before it completes.
+func (k *KinD) ListClustersContext(ctx context.Context) ([]string, error) {
+	b := &bytes.Buffer{}
+	if err := k.run(ctx, b, os.Stderr, k.path(), "get", "clusters"); err != nil {
+		return nil, fmt.Errorf("unable to list kind clusters: %w", err)
+	}
+	r := []string{}
+	for _, s := range strings.Split(b.String(), "\n") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			r = append(r, s)
+		}
 	}
-	return r
+	return r, nil
 }
 
-func (k *KinD) Start(name, version string) (*KinDCluster, error) {
+type startOptions struct {
+	configFile      string
+	extraCreateArgs []string
+}
+
+// reservedCreateArgs are the kind create flags set by Start that can not be overridden.
+var reservedCreateArgs = []string{"--name", "--image"}
+
+func (o startOptions) validate() error {
+	for _, arg := range o.extraCreateArgs {
+		for _, reserved := range reservedCreateArgs {
+			if arg == reserved || strings.HasPrefix(arg, reserved+"=") {
+				return fmt.Errorf("%s is set by Start and can not be passed as an extra create argument", reserved)
+			}
+		}
+	}
+	return nil
+}
+
+// WithExtraCreateArgs appends args, like --retain or --wait 120s, to the kind create cluster command.
+// The cluster name and node image are set by Start and can not be passed.
+func WithExtraCreateArgs(args ...string) func(o *startOptions) {
+	return func(o *startOptions) {
+		o.extraCreateArgs = append(o.extraCreateArgs, args...)
+	}
+}
+
+// WithConfigFile makes the cluster be created from the given kind configuration file,
+// allowing multi-node clusters or port mappings.
+func WithConfigFile(path string) func(o *startOptions) {
+	return func(o *startOptions) {
+		o.configFile = path
+	}
+}
+
+// Start creates the cluster when it does not exist yet and waits for it to be ready.
+// When ReuseEnv is enabled, an existing cluster with a valid kubeconfig is returned right away.
+func (k *KinD) Start(name, version string, opts ...func(o *startOptions)) (*KinDCluster, error) {
+	return k.StartContext(context.Background(), name, version, opts...)
+}
+
+// StartContext behaves like Start but stops waiting for the cluster to be ready, returning ctx.Err(),
+// when ctx is cancelled or its deadline passes.
+func (k *KinD) StartContext(ctx context.Context, name, version string, opts ...func(o *startOptions)) (*KinDCluster, error) {
+	options := startOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
 	_, err := os.Stat(k.path())
 	if err != nil {
 		if err := k.Install(); err != nil {
@@ -70,7 +255,8 @@ func (k *KinD) Start(name, version string) (*KinDCluster, error) {
 		kind:    k,
 	}
 	os.Setenv("KUBECONFIG", cluster.KubeConfigPath())
-	if !cluster.Exists() {
+	exists := cluster.Exists()
+	if !exists {
 		err := os.MkdirAll(filepath.Dir(cluster.KubeConfigPath()), 0777)
 		if err != nil {
 			return nil, err
@@ -81,100 +267,221 @@ func (k *KinD) Start(name, version string) (*KinDCluster, error) {
 		} else {
 			os.Remove(cluster.KubeConfigPath())
 		}
-		c := exec.Command(k.path(), args...)
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
-		err = c.Run()
+		if options.configFile != "" {
+			args = append(args, "--config", options.configFile)
+		}
+		args = append(args, options.extraCreateArgs...)
+		err = k.run(ctx, os.Stdout, os.Stderr, k.path(), args...)
 		if err != nil {
-			dir, _ := ioutil.TempDir("", "example")
-			if err != nil {
-				return nil, err
-			}
-			defer os.RemoveAll(dir)
-
-			c := exec.Command(k.path(), "export", "logs", dir, "--name", cluster.ID())
-			c.Stdout = os.Stdout
-			c.Stderr = os.Stderr
-			c.Run()
-			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-				if info.IsDir() {
-					return nil
-				}
-				fmt.Println("######", path)
-				fd, err := os.Open(path)
-				if err != nil {
-					return err
-				}
-				defer fd.Close()
-				_, err = io.Copy(os.Stdout, fd)
-				return err
-			})
-			return nil, err
+			return nil, k.createFailed(cluster, err)
 		}
 	}
 	err = cluster.DownloadKubeConfig()
 	if err != nil {
 		return cluster, err
 	}
-	for {
-		cfg, err := NewClientConfigBuilder().WithKubeConfigPath(cluster.KubeConfigPath()).Build()
+	if exists && reuseEnabled() {
+		if _, err := cluster.RestConfig(); err == nil {
+			return cluster, nil
+		}
+	}
+	if err := k.waitUntilReady(ctx, cluster); err != nil {
+		return cluster, err
+	}
+	return cluster, nil
+}
+
+// createFailed exports the logs of the cluster that failed to be created and prints them to LogsOutput.
+func (k *KinD) createFailed(cluster *KinDCluster, err error) error {
+	createErr := &CreateClusterError{Cluster: cluster.ID(), Err: err}
+	dir, tempErr := os.MkdirTemp("", "kind-logs-"+cluster.ID())
+	if tempErr != nil {
+		return createErr
+	}
+	if exportErr := cluster.ExportLogs(dir); exportErr != nil {
+		os.RemoveAll(dir)
+		return createErr
+	}
+	createErr.LogsDir = dir
+	output := k.LogsOutput
+	if output == nil {
+		output = os.Stdout
+	}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return nil, err
+			return err
 		}
-		client, err := k8sclient.New(cfg, k8sclient.Options{})
+		if info.IsDir() {
+			return nil
+		}
+		fmt.Fprintln(output, "######", path)
+		fd, err := os.Open(path)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		pods := v1.PodList{}
-		if err = client.List(context.Background(), &pods); err == nil {
-			if len(pods.Items) >= 8 {
-				// all required pods seems to be there, checking they are ready
-				initialized := true
-				for _, p := range pods.Items {
-					if p.Status.Phase != "Running" {
-						initialized = false
-					}
-				}
-				if initialized {
-					break
-				}
+		defer fd.Close()
+		_, err = io.Copy(output, fd)
+		return err
+	})
+	return createErr
+}
+
+func (k *KinD) waitUntilReady(ctx context.Context, cluster *KinDCluster) error {
+	for {
+		client, err := cluster.Client()
+		if err != nil {
+			return err
+		}
+		nodes := v1.NodeList{}
+		if err = client.List(ctx, &nodes); err == nil && nodesReady(nodes.Items) {
+			if k.MinReadyPods == 0 {
+				return nil
+			}
+			pods := v1.PodList{}
+			if err = client.List(ctx, &pods); err == nil && podsReady(pods.Items, k.MinReadyPods) {
+				return nil
 			}
 		}
 		fmt.Println("cluster is still initializing, waiting a bit")
-		time.Sleep(500 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
 	}
-	return cluster, nil
+}
+
+// nodesReady tells whether there is at least one node and all nodes report the Ready condition.
+func nodesReady(nodes []v1.Node) bool {
+	if len(nodes) == 0 {
+		return false
+	}
+	for _, node := range nodes {
+		ready := false
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == v1.NodeReady && condition.Status == v1.ConditionTrue {
+				ready = true
+			}
+		}
+		if !ready {
+			return false
+		}
+	}
+	return true
+}
+
+// podsReady tells whether there are at least min pods and all of them are running.
+func podsReady(pods []v1.Pod, min int) bool {
+	if len(pods) < min {
+		return false
+	}
+	for _, p := range pods {
+		if p.Status.Phase != v1.PodRunning {
+			return false
+		}
+	}
+	return true
 }
 
 func (k *KinD) Delete(cluster *KinDCluster) error {
-	c := exec.Command(k.path(), "delete", "cluster", "--name", cluster.ID())
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	err := c.Run()
+	err := k.run(context.Background(), os.Stdout, os.Stderr, k.path(), "delete", "cluster", "--name", cluster.ID())
 	if err != nil {
 		return err
 	}
 	return os.Remove(cluster.KubeConfigPath())
 }
 
+// DeleteAll deletes all the kind clusters and their kubeconfig files in Dir.
+// It fails when the clusters can not be listed.
+func (k *KinD) DeleteAll() error {
+	errs := []error{}
+	clusters, err := k.ListClustersContext(context.Background())
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, id := range clusters {
+		err := k.run(context.Background(), os.Stdout, os.Stderr, k.path(), "delete", "cluster", "--name", id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to delete cluster %s: %w", id, err))
+			continue
+		}
+		err = os.Remove(filepath.Join(k.Dir, ".kube", "config-"+id))
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Install downloads the kind binary to Dir, regardless of PreferSystemBinary.
 func (k *KinD) Install() error {
+	httpClient := k.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
 	// map linux (GOOS) to Linux (result of uname), darwin (GOOS) to Darwin (result of uname)
-	resp, err := http.Get(fmt.Sprintf("https://kind.sigs.k8s.io/dl/%s/kind-%s-%s", k.Version, strings.Title(runtime.GOOS), runtime.GOARCH))
+	resp, err := k.download(httpClient, fmt.Sprintf("https://kind.sigs.k8s.io/dl/%s/kind-%s-%s", k.Version, strings.Title(runtime.GOOS), runtime.GOARCH))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	err = os.MkdirAll(filepath.Dir(k.path()), 0777)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to download kind %s: unexpected status %s", k.Version, resp.Status)
+	}
+	err = os.MkdirAll(filepath.Dir(k.installPath()), 0777)
 	if err != nil {
 		return err
 	}
-	fd, err := os.OpenFile(k.path(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	fd, err := os.OpenFile(k.installPath(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
 	if err != nil {
 		return err
 	}
 	defer fd.Close()
-	_, err = io.Copy(fd, resp.Body)
-	return err
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(fd, hash), resp.Body)
+	if err != nil {
+		return err
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	expected, ok := k.Checksum[platform]
+	if !ok {
+		fmt.Printf("warning: no checksum configured for kind %s on %s, the downloaded binary is not verified\n", k.Version, platform)
+		return nil
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		fd.Close()
+		os.Remove(k.installPath())
+		return fmt.Errorf("kind %s checksum mismatch on %s: expected %s, got %s", k.Version, platform, expected, actual)
+	}
+	return nil
+}
+
+// download gets url, retrying with an exponential backoff when the request fails.
+func (k *KinD) download(httpClient *http.Client, url string) (*http.Response, error) {
+	retries := k.InstallRetries
+	switch {
+	case retries == 0:
+		retries = DefaultInstallRetries
+	case retries < 0:
+		retries = 0
+	}
+	delay := k.InstallBackoff
+	if delay == 0 {
+		delay = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := httpClient.Get(url)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= retries {
+			return nil, fmt.Errorf("unable to download kind %s after %d attempts: %w", k.Version, attempt+1, err)
+		}
+		fmt.Printf("unable to download kind %s, retrying in %s: %v\n", k.Version, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
 }
 
 func (k *KinD) Exists(name string) bool {
@@ -187,19 +494,62 @@ func (k *KinD) Exists(name string) bool {
 }
 
 func (k *KinD) DownloadKubeConfig(name string) (string, error) {
-	c := exec.Command(k.path(), "get", "kubeconfig", "--name", name)
 	b := &bytes.Buffer{}
-	c.Stdout = b
-	c.Stderr = os.Stderr
-	if err := c.Run(); err != nil {
+	if err := k.run(context.Background(), b, os.Stderr, k.path(), "get", "kubeconfig", "--name", name); err != nil {
 		return "", err
 	}
 	return b.String(), nil
 }
 func (k *KinD) path() string {
+	if k.PreferSystemBinary {
+		if path := k.cachedSystemBinary(); path != "" {
+			return path
+		}
+	}
+	return k.installPath()
+}
+
+// cachedSystemBinary resolves the system binary once per version, so all the commands run the same binary.
+func (k *KinD) cachedSystemBinary() string {
+	v, _ := systemBinaries.LoadOrStore(k, &systemBinaryCache{})
+	cache := v.(*systemBinaryCache)
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if !cache.resolved || cache.version != k.Version {
+		cache.path, _ = k.systemBinary()
+		cache.version = k.Version
+		cache.resolved = true
+	}
+	return cache.path
+}
+
+// installPath is the path Install downloads the kind binary to.
+func (k *KinD) installPath() string {
 	return filepath.Join(k.Dir, "bin", "kind-"+k.Version)
 }
 
+// systemBinary returns the path of the kind binary in the PATH, when it reports the expected version.
+func (k *KinD) systemBinary() (string, bool) {
+	lookPath := k.LookPath
+	if lookPath == nil {
+		lookPath = exec.LookPath
+	}
+	path, err := lookPath("kind")
+	if err != nil {
+		return "", false
+	}
+	b := &bytes.Buffer{}
+	if err := k.run(context.Background(), b, io.Discard, path, "version"); err != nil {
+		return "", false
+	}
+	// kind version prints something like: kind v0.22.0 go1.21.7 linux/amd64
+	fields := strings.Fields(b.String())
+	if len(fields) < 2 || fields[1] != k.Version {
+		return "", false
+	}
+	return path, true
+}
+
 func (k *KinDCluster) DownloadKubeConfig() error {
 	_, err := os.Stat(k.KubeConfigPath())
 	if err == nil {
@@ -218,6 +568,35 @@ func (k *KinDCluster) DownloadKubeConfig() error {
 	return err
 }
 
+// LoadDockerImage makes the local docker image available inside the cluster nodes.
+func (k *KinDCluster) LoadDockerImage(image string) error {
+	stderr := &bytes.Buffer{}
+	err := k.kind.run(context.Background(), os.Stdout, stderr, k.kind.path(), "load", "docker-image", image, "--name", k.ID())
+	if err != nil {
+		return fmt.Errorf("unable to load image %s: %w: %s", image, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// RestConfig returns the configuration to connect to the cluster.
+func (k *KinDCluster) RestConfig() (*rest.Config, error) {
+	return NewClientConfigBuilder().WithKubeConfigPath(k.KubeConfigPath()).Build()
+}
+
+// Client returns a client connected to the cluster.
+func (k *KinDCluster) Client() (k8sclient.Client, error) {
+	cfg, err := k.RestConfig()
+	if err != nil {
+		return nil, err
+	}
+	return k8sclient.New(cfg, k8sclient.Options{})
+}
+
+// ExportLogs exports the logs of the cluster nodes to dir.
+func (k *KinDCluster) ExportLogs(dir string) error {
+	return k.kind.run(context.Background(), os.Stdout, os.Stderr, k.kind.path(), "export", "logs", dir, "--name", k.ID())
+}
+
 func (k *KinDCluster) ID() string {
 	return k.name + "-" + k.version
 }
@@ -229,6 +608,52 @@ func (k *KinDCluster) KubeConfigPath() string {
 	return filepath.Join(k.dir, ".kube", "config-"+k.ID())
 }
 
+// kindVersions maps Kubernetes minor versions to a kind release providing node images for them.
+var kindVersions = map[int]string{
+	14: "v0.11.1",
+	15: "v0.11.1",
+	16: "v0.11.1",
+	17: "v0.11.1",
+	18: "v0.11.1",
+	19: "v0.11.1",
+	20: "v0.11.1",
+	21: "v0.11.1",
+	22: "v0.17.0",
+	23: "v0.20.0",
+	24: "v0.20.0",
+	25: "v0.20.0",
+	26: "v0.20.0",
+	27: "v0.20.0",
+	28: "v0.22.0",
+	29: "v0.22.0",
+	30: "v0.23.0",
+	31: "v0.24.0",
+}
+
+// KinDForVersion returns a KinD able to start clusters of the given Kubernetes version, like v1.29.2.
+// A kind release, like v0.22.0, can also be given to use that specific release.
+// The default version is used when the version can't be parsed.
 func KinDForVersion(version string) *KinD {
-	return &DefaultKind
+	kind := DefaultKind
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return &kind
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return &kind
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return &kind
+	}
+	switch {
+	case major == 0:
+		kind.Version = "v" + strings.TrimPrefix(version, "v")
+	case major == 1 && kindVersions[minor] != "":
+		kind.Version = kindVersions[minor]
+	case major == 1 && minor > 31:
+		kind.Version = kindVersions[31]
+	}
+	return &kind
 }
@@ -2,14 +2,28 @@ package k8s_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	k8s "github.com/adevinta/go-k8s-toolkit"
+	testutils "github.com/adevinta/go-testutils-toolkit"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -17,11 +31,15 @@ func TestKind(t *testing.T) {
 	kind := k8s.KinDForVersion("v1.15.3")
 	cluster, err := kind.Start("kind-test", "v1.15.3")
 	require.NoError(t, err)
+	deleted := false
+	t.Cleanup(func() {
+		if !deleted {
+			assert.NoError(t, kind.Delete(cluster))
+		}
+	})
 	assert.Equal(t, ".kind/.kube/config-kind-test-v1.15.3", cluster.KubeConfigPath())
-	cfg, err := k8s.NewClientConfigBuilder().WithKubeConfigPath(cluster.KubeConfigPath()).Build()
-	assert.NoError(t, err)
-	client, err := k8sclient.New(cfg, k8sclient.Options{})
-	assert.NoError(t, err)
+	client, err := cluster.Client()
+	require.NoError(t, err)
 	pods := v1.PodList{}
 	assert.NoError(t, client.List(context.Background(), &pods))
 	expectedPods := map[string]interface{}{
@@ -42,8 +60,793 @@ func TestKind(t *testing.T) {
 		}
 	}
 	assert.Len(t, expectedPods, 0)
+
+	require.NoError(t, k8s.ApplyManifests(context.Background(), client, strings.NewReader(namespaceBundle)))
+	cm := v1.ConfigMap{}
+	assert.NoError(t, client.Get(context.Background(), k8sclient.ObjectKey{Namespace: "my-namespace", Name: "config"}, &cm))
+	assert.Equal(t, map[string]string{"key": "value"}, cm.Data)
+	deleted = true
 	assert.NoError(t, kind.Delete(cluster))
 	assert.Error(t, client.List(context.Background(), &pods))
 	_, err = os.Stat(cluster.KubeConfigPath())
 	assert.True(t, os.IsNotExist(err))
 }
+
+// installFakeKinD writes a shell script in place of the kind binary of kind, running script with the kind arguments.
+func installFakeKinD(t *testing.T, kind *k8s.KinD, script string) {
+	t.Helper()
+	path := filepath.Join(kind.Dir, "bin", "kind-"+kind.Version)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+}
+
+func writeKinDKubeConfig(t *testing.T, path, server string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(`apiVersion: v1
+kind: Config
+current-context: test
+contexts:
+- name: test
+  context:
+    cluster: test
+clusters:
+- name: test
+  cluster:
+    server: `+server+`
+`), 0600))
+}
+
+func TestKinDStartContextTimesOutWhileWaitingForReadiness(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake"}
+	installFakeKinD(t, kind, `echo never-ready-v1.29.0`)
+	writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-never-ready-v1.29.0"), server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := kind.StartContext(ctx, "never-ready", "v1.29.0")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestKinDStartContextWaitsForReadyNodes(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	node := func(name string, status v1.ConditionStatus) v1.Node {
+		return v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1.NodeStatus{
+				Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: status}},
+			},
+		}
+	}
+
+	t.Run("when all nodes are ready", func(t *testing.T) {
+		server := newDiscoveryServer(t, map[string]interface{}{
+			"/api/v1/nodes": v1.NodeList{
+				TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"},
+				Items:    []v1.Node{node("control-plane", v1.ConditionTrue), node("worker", v1.ConditionTrue)},
+			},
+		})
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake"}
+		installFakeKinD(t, kind, `echo ready-v1.29.0`)
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-ready-v1.29.0"), server.URL)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cluster, err := kind.StartContext(ctx, "ready", "v1.29.0")
+		require.NoError(t, err)
+		assert.Equal(t, "ready-v1.29.0", cluster.ID())
+	})
+
+	t.Run("when a node is not ready", func(t *testing.T) {
+		server := newDiscoveryServer(t, map[string]interface{}{
+			"/api/v1/nodes": v1.NodeList{
+				TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"},
+				Items:    []v1.Node{node("control-plane", v1.ConditionTrue), node("worker", v1.ConditionFalse)},
+			},
+		})
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake"}
+		installFakeKinD(t, kind, `echo not-ready-v1.29.0`)
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-not-ready-v1.29.0"), server.URL)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := kind.StartContext(ctx, "not-ready", "v1.29.0")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func newReadyNodesServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newDiscoveryServer(t, map[string]interface{}{
+		"/api/v1/nodes": v1.NodeList{
+			TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"},
+			Items: []v1.Node{{
+				ObjectMeta: metav1.ObjectMeta{Name: "control-plane"},
+				Status: v1.NodeStatus{
+					Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+				},
+			}},
+		},
+	})
+}
+
+func TestKinDStartWithConfigFile(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	server := newReadyNodesServer(t)
+
+	t.Run("the config file is forwarded to kind create", func(t *testing.T) {
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake"}
+		argsFile := filepath.Join(kind.Dir, "args")
+		installFakeKinD(t, kind, `echo "$@" >> `+argsFile)
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-multi-node-v1.29.0"), server.URL)
+
+		_, err := kind.Start("multi-node", "v1.29.0", k8s.WithConfigFile("./kind-config.yaml"))
+		require.NoError(t, err)
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(args), "create cluster --image kindest/node:v1.29.0 --name multi-node-v1.29.0 --kubeconfig "+filepath.Join(kind.Dir, ".kube", "config-multi-node-v1.29.0")+" --config ./kind-config.yaml\n")
+	})
+
+	t.Run("without config file, no flag is added", func(t *testing.T) {
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake"}
+		argsFile := filepath.Join(kind.Dir, "args")
+		installFakeKinD(t, kind, `echo "$@" >> `+argsFile)
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-single-node-v1.29.0"), server.URL)
+
+		_, err := kind.Start("single-node", "v1.29.0")
+		require.NoError(t, err)
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(args), "create cluster")
+		assert.NotContains(t, string(args), "--config")
+	})
+}
+
+type fakeCommandRunner struct {
+	lock    sync.Mutex
+	names   []string
+	calls   [][]string
+	envs    [][]string
+	outputs map[string]string
+	stderrs map[string]string
+	errors  map[string]error
+}
+
+func (f *fakeCommandRunner) Run(ctx context.Context, env []string, stdout, stderr io.Writer, name string, args ...string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.names = append(f.names, name)
+	f.calls = append(f.calls, args)
+	f.envs = append(f.envs, env)
+	command := strings.Join(args, " ")
+	io.WriteString(stdout, f.outputs[command])
+	io.WriteString(stderr, f.stderrs[command])
+	return f.errors[command]
+}
+
+func TestKinDListClusters(t *testing.T) {
+	t.Run("lists the clusters reported by kind", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"get clusters": "kind-a \n kind-b"}}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+		assert.Equal(t, []string{"kind-a", "kind-b"}, kind.ListClusters())
+		assert.Equal(t, [][]string{{"get", "clusters"}}, runner.calls)
+		assert.True(t, kind.Exists("kind-b"))
+		assert.False(t, kind.Exists("kind-c"))
+	})
+
+	t.Run("empty lines are ignored", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"get clusters": "a\nb\n"}}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+		assert.Equal(t, []string{"a", "b"}, kind.ListClusters())
+		assert.False(t, kind.Exists(""))
+	})
+
+	t.Run("command failures are reported with context", func(t *testing.T) {
+		runner := &fakeCommandRunner{errors: map[string]error{"get clusters": errors.New("Cannot connect to the Docker daemon")}}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+		clusters, err := kind.ListClustersContext(context.Background())
+		assert.EqualError(t, err, "unable to list kind clusters: Cannot connect to the Docker daemon")
+		assert.Nil(t, clusters)
+		assert.Empty(t, kind.ListClusters())
+	})
+
+	t.Run("no cluster is not an error", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"get clusters": "\n"}}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+		clusters, err := kind.ListClustersContext(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, clusters)
+	})
+
+	t.Run("when there is no cluster", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"get clusters": "\n"}}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+		assert.Empty(t, kind.ListClusters())
+	})
+
+	t.Run("when kind fails, no cluster is listed", func(t *testing.T) {
+		runner := &fakeCommandRunner{
+			outputs: map[string]string{"get clusters": "kind-a"},
+			errors:  map[string]error{"get clusters": errors.New("kind failed")},
+		}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+		assert.Empty(t, kind.ListClusters())
+	})
+}
+
+func TestKinDDownloadKubeConfigUsesRunner(t *testing.T) {
+	runner := &fakeCommandRunner{outputs: map[string]string{"get kubeconfig --name test-v1.29.0": "apiVersion: v1\nkind: Config\n"}}
+	kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+	config, err := kind.DownloadKubeConfig("test-v1.29.0")
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: v1\nkind: Config\n", config)
+}
+
+// startFakeKinDCluster starts the cluster test-v1.29.0 with runner, serving ready nodes.
+func startFakeKinDCluster(t *testing.T, runner k8s.CommandRunner) (*k8s.KinD, *k8s.KinDCluster) {
+	t.Helper()
+	t.Setenv("KUBECONFIG", "")
+	server := newReadyNodesServer(t)
+	kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+	// the binary only needs to exist to avoid downloading kind
+	installFakeKinD(t, kind, "exit 1")
+	writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-test-v1.29.0"), server.URL)
+
+	cluster, err := kind.Start("test", "v1.29.0")
+	require.NoError(t, err)
+	return kind, cluster
+}
+
+func TestKinDStartAndDeleteUseRunner(t *testing.T) {
+	runner := &fakeCommandRunner{}
+	kind, cluster := startFakeKinDCluster(t, runner)
+	require.NoError(t, kind.Delete(cluster))
+
+	assert.Equal(t, [][]string{
+		{"get", "clusters"},
+		{"create", "cluster", "--image", "kindest/node:v1.29.0", "--name", "test-v1.29.0", "--kubeconfig", cluster.KubeConfigPath()},
+		{"delete", "cluster", "--name", "test-v1.29.0"},
+	}, runner.calls)
+	_, err := os.Stat(cluster.KubeConfigPath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestKinDStartWithExtraCreateArgs(t *testing.T) {
+	t.Run("the extra args are appended to kind create", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "")
+		server := newReadyNodesServer(t)
+		runner := &fakeCommandRunner{}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+		installFakeKinD(t, kind, "exit 1")
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-test-v1.29.0"), server.URL)
+
+		cluster, err := kind.Start("test", "v1.29.0", k8s.WithConfigFile("./kind-config.yaml"), k8s.WithExtraCreateArgs("--retain", "--wait", "120s"))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"create", "cluster", "--image", "kindest/node:v1.29.0", "--name", "test-v1.29.0", "--kubeconfig", cluster.KubeConfigPath(), "--config", "./kind-config.yaml", "--retain", "--wait", "120s"}, runner.calls[1])
+	})
+
+	for _, arg := range []string{"--name", "--image=kindest/node:v1.30.0"} {
+		t.Run(arg+" can not be overridden", func(t *testing.T) {
+			runner := &fakeCommandRunner{}
+			kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+			_, err := kind.Start("test", "v1.29.0", k8s.WithExtraCreateArgs("--retain", arg))
+			assert.ErrorContains(t, err, "can not be passed as an extra create argument")
+			assert.Empty(t, runner.calls)
+		})
+	}
+}
+
+func TestKinDStartReusesExistingCluster(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: &fakeCommandRunner{outputs: map[string]string{"get clusters": "test-v1.29.0\n"}}}
+	installFakeKinD(t, kind, "exit 1")
+	// nothing listens on this address, the cluster never becomes ready
+	writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-test-v1.29.0"), "https://127.0.0.1:1")
+
+	t.Run("when reuse is enabled the readiness is not checked", func(t *testing.T) {
+		t.Setenv("KIND_REUSE", "1")
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		cluster, err := kind.StartContext(ctx, "test", "v1.29.0")
+		require.NoError(t, err)
+		assert.Equal(t, "test-v1.29.0", cluster.ID())
+	})
+
+	t.Run("when reuse is disabled the readiness is checked", func(t *testing.T) {
+		t.Setenv("KIND_REUSE", "")
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := kind.StartContext(ctx, "test", "v1.29.0")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestKinDClusterLoadDockerImage(t *testing.T) {
+	runner := &fakeCommandRunner{
+		stderrs: map[string]string{"load docker-image missing:latest --name test-v1.29.0": "image: \"missing:latest\" not present locally\n"},
+		errors:  map[string]error{"load docker-image missing:latest --name test-v1.29.0": errors.New("exit status 1")},
+	}
+	_, cluster := startFakeKinDCluster(t, runner)
+
+	require.NoError(t, cluster.LoadDockerImage("controller:dev"))
+	assert.Equal(t, []string{"load", "docker-image", "controller:dev", "--name", "test-v1.29.0"}, runner.calls[len(runner.calls)-1])
+
+	err := cluster.LoadDockerImage("missing:latest")
+	assert.EqualError(t, err, `unable to load image missing:latest: exit status 1: image: "missing:latest" not present locally`)
+}
+
+func stubHTTPClient(status int, body string) *http.Client {
+	return &http.Client{Transport: testutils.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+}
+
+func TestKinDInstallVerifiesChecksum(t *testing.T) {
+	binary := "#!/bin/sh\necho kind\n"
+	sum := sha256.Sum256([]byte(binary))
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+
+	t.Run("with a matching checksum", func(t *testing.T) {
+		kind := &k8s.KinD{
+			Dir:        t.TempDir(),
+			Version:    "v0.22.0",
+			HTTPClient: stubHTTPClient(http.StatusOK, binary),
+			Checksum:   map[string]string{platform: hex.EncodeToString(sum[:])},
+		}
+		require.NoError(t, kind.Install())
+		content, err := os.ReadFile(filepath.Join(kind.Dir, "bin", "kind-v0.22.0"))
+		require.NoError(t, err)
+		assert.Equal(t, binary, string(content))
+	})
+
+	t.Run("with a mismatching checksum", func(t *testing.T) {
+		kind := &k8s.KinD{
+			Dir:        t.TempDir(),
+			Version:    "v0.22.0",
+			HTTPClient: stubHTTPClient(http.StatusOK, "tampered"),
+			Checksum:   map[string]string{platform: hex.EncodeToString(sum[:])},
+		}
+		err := kind.Install()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum mismatch")
+		_, err = os.Stat(filepath.Join(kind.Dir, "bin", "kind-v0.22.0"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("without checksum", func(t *testing.T) {
+		kind := &k8s.KinD{
+			Dir:        t.TempDir(),
+			Version:    "v0.22.0",
+			HTTPClient: stubHTTPClient(http.StatusOK, binary),
+		}
+		require.NoError(t, kind.Install())
+		_, err := os.Stat(filepath.Join(kind.Dir, "bin", "kind-v0.22.0"))
+		assert.NoError(t, err)
+	})
+}
+
+func TestKinDPreferSystemBinary(t *testing.T) {
+	lookPath := func(path string, err error) func(string) (string, error) {
+		return func(file string) (string, error) {
+			assert.Equal(t, "kind", file)
+			return path, err
+		}
+	}
+	dir := t.TempDir()
+	downloaded := filepath.Join(dir, "bin", "kind-v0.22.0")
+
+	t.Run("the system binary is used when it has the expected version", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"version": "kind v0.22.0 go1.21.7 linux/amd64\n"}}
+		kind := &k8s.KinD{Dir: dir, Version: "v0.22.0", Runner: runner, PreferSystemBinary: true, LookPath: lookPath("/usr/local/bin/kind", nil)}
+		kind.ListClusters()
+		assert.Equal(t, "/usr/local/bin/kind", runner.names[len(runner.names)-1])
+	})
+
+	t.Run("the downloaded binary is used when the system one has another version", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"version": "kind v0.20.0 go1.20.4 linux/amd64\n"}}
+		kind := &k8s.KinD{Dir: dir, Version: "v0.22.0", Runner: runner, PreferSystemBinary: true, LookPath: lookPath("/usr/local/bin/kind", nil)}
+		kind.ListClusters()
+		assert.Equal(t, downloaded, runner.names[len(runner.names)-1])
+	})
+
+	t.Run("the downloaded binary is used when there is no system binary", func(t *testing.T) {
+		runner := &fakeCommandRunner{}
+		kind := &k8s.KinD{Dir: dir, Version: "v0.22.0", Runner: runner, PreferSystemBinary: true, LookPath: lookPath("", exec.ErrNotFound)}
+		kind.ListClusters()
+		assert.Equal(t, []string{downloaded}, runner.names)
+	})
+
+	t.Run("the system binary is resolved once", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"version": "kind v0.22.0 go1.21.7 linux/amd64\n"}}
+		kind := &k8s.KinD{Dir: dir, Version: "v0.22.0", Runner: runner, PreferSystemBinary: true, LookPath: lookPath("/usr/local/bin/kind", nil)}
+		kind.ListClusters()
+		kind.ListClusters()
+		assert.Equal(t, [][]string{{"version"}, {"get", "clusters"}, {"get", "clusters"}}, runner.calls)
+	})
+
+	t.Run("the system binary is resolved once by concurrent commands", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"version": "kind v0.22.0 go1.21.7 linux/amd64\n"}}
+		kind := &k8s.KinD{Dir: dir, Version: "v0.22.0", Runner: runner, PreferSystemBinary: true, LookPath: lookPath("/usr/local/bin/kind", nil)}
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				kind.ListClusters()
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, []string{"version"}, runner.calls[0])
+		assert.Len(t, runner.calls, 11)
+	})
+
+	t.Run("Install never touches the system binary", func(t *testing.T) {
+		systemDir := t.TempDir()
+		systemBinary := filepath.Join(systemDir, "kind")
+		require.NoError(t, os.WriteFile(systemBinary, []byte("system kind"), 0755))
+		sum := sha256.Sum256([]byte("expected"))
+		runner := &fakeCommandRunner{outputs: map[string]string{"version": "kind v0.22.0 go1.21.7 linux/amd64\n"}}
+		kind := &k8s.KinD{
+			Dir:                t.TempDir(),
+			Version:            "v0.22.0",
+			Runner:             runner,
+			PreferSystemBinary: true,
+			LookPath:           lookPath(systemBinary, nil),
+			HTTPClient:         stubHTTPClient(http.StatusOK, "tampered"),
+			Checksum:           map[string]string{runtime.GOOS + "/" + runtime.GOARCH: hex.EncodeToString(sum[:])},
+		}
+		kind.ListClusters()
+		require.Equal(t, systemBinary, runner.names[len(runner.names)-1])
+
+		assert.ErrorContains(t, kind.Install(), "checksum mismatch")
+		content, err := os.ReadFile(systemBinary)
+		require.NoError(t, err)
+		assert.Equal(t, "system kind", string(content))
+
+		kind.HTTPClient = stubHTTPClient(http.StatusOK, "expected")
+		require.NoError(t, kind.Install())
+		content, err = os.ReadFile(systemBinary)
+		require.NoError(t, err)
+		assert.Equal(t, "system kind", string(content))
+		content, err = os.ReadFile(filepath.Join(kind.Dir, "bin", "kind-v0.22.0"))
+		require.NoError(t, err)
+		assert.Equal(t, "expected", string(content))
+	})
+
+	t.Run("the system binary is ignored by default", func(t *testing.T) {
+		runner := &fakeCommandRunner{}
+		kind := &k8s.KinD{Dir: dir, Version: "v0.22.0", Runner: runner, LookPath: lookPath("/usr/local/bin/kind", nil)}
+		kind.ListClusters()
+		assert.Equal(t, []string{downloaded}, runner.names)
+	})
+}
+
+func TestKinDWithProvider(t *testing.T) {
+	t.Setenv("KIND_EXPERIMENTAL_PROVIDER", "")
+	server := newReadyNodesServer(t)
+
+	t.Run("the provider is passed to kind create", func(t *testing.T) {
+		kind := (&k8s.KinD{Dir: t.TempDir(), Version: "fake"}).WithProvider("podman")
+		argsFile := filepath.Join(kind.Dir, "args")
+		installFakeKinD(t, kind, `echo "provider=$KIND_EXPERIMENTAL_PROVIDER $@" >> `+argsFile)
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-podman-v1.29.0"), server.URL)
+
+		_, err := kind.Start("podman", "v1.29.0")
+		require.NoError(t, err)
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(args), "provider=podman create cluster")
+	})
+
+	t.Run("the provider is passed through an explicit ExecCommandRunner", func(t *testing.T) {
+		runner := &k8s.ExecCommandRunner{Env: []string{"KIND_TEST=set"}}
+		kind := (&k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}).WithProvider("podman")
+		argsFile := filepath.Join(kind.Dir, "args")
+		installFakeKinD(t, kind, `echo "provider=$KIND_EXPERIMENTAL_PROVIDER test=$KIND_TEST $@" >> `+argsFile)
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-podman-v1.29.0"), server.URL)
+
+		_, err := kind.Start("podman", "v1.29.0")
+		require.NoError(t, err)
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(args), "provider=podman test=set create cluster")
+		assert.Equal(t, []string{"KIND_TEST=set"}, runner.Env)
+	})
+
+	t.Run("the provider is passed to custom runners", func(t *testing.T) {
+		runner := &fakeCommandRunner{}
+		kind := (&k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}).WithProvider("podman")
+		kind.ListClusters()
+		require.Len(t, runner.envs, 1)
+		assert.Equal(t, []string{"KIND_EXPERIMENTAL_PROVIDER=podman"}, runner.envs[0])
+
+		runner = &fakeCommandRunner{}
+		(&k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}).ListClusters()
+		require.Len(t, runner.envs, 1)
+		assert.Empty(t, runner.envs[0])
+	})
+
+	t.Run("the receiver is left unchanged", func(t *testing.T) {
+		base := &k8s.KinD{Dir: t.TempDir(), Version: "fake"}
+		kind := base.WithProvider("podman")
+		assert.NotSame(t, base, kind)
+
+		argsFile := filepath.Join(base.Dir, "args")
+		installFakeKinD(t, base, `echo "provider=$KIND_EXPERIMENTAL_PROVIDER $@" >> `+argsFile)
+		writeKinDKubeConfig(t, filepath.Join(base.Dir, ".kube", "config-base-v1.29.0"), server.URL)
+
+		_, err := base.Start("base", "v1.29.0")
+		require.NoError(t, err)
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(args), "provider= create cluster")
+	})
+
+	t.Run("docker is used by default", func(t *testing.T) {
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake"}
+		argsFile := filepath.Join(kind.Dir, "args")
+		installFakeKinD(t, kind, `echo "provider=$KIND_EXPERIMENTAL_PROVIDER $@" >> `+argsFile)
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-docker-v1.29.0"), server.URL)
+
+		_, err := kind.Start("docker", "v1.29.0")
+		require.NoError(t, err)
+
+		args, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(args), "provider= create cluster")
+	})
+}
+
+func TestKinDClusterClient(t *testing.T) {
+	_, cluster := startFakeKinDCluster(t, &fakeCommandRunner{})
+
+	cfg, err := cluster.RestConfig()
+	require.NoError(t, err)
+	assert.NotEmpty(t, cfg.Host)
+
+	client, err := cluster.Client()
+	require.NoError(t, err)
+	nodes := v1.NodeList{}
+	require.NoError(t, client.List(context.Background(), &nodes))
+	require.Len(t, nodes.Items, 1)
+	assert.Equal(t, "control-plane", nodes.Items[0].Name)
+}
+
+func TestKinDInstallRetriesTransientFailures(t *testing.T) {
+	flakyClient := func(failures int, attempts *int) *http.Client {
+		return &http.Client{Transport: testutils.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			*attempts++
+			if *attempts <= failures {
+				return nil, errors.New("connection reset by peer")
+			}
+			return stubHTTPClient(http.StatusOK, "kind").Transport.RoundTrip(r)
+		})}
+	}
+
+	t.Run("when the download eventually succeeds", func(t *testing.T) {
+		attempts := 0
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "v0.22.0", HTTPClient: flakyClient(2, &attempts), InstallBackoff: time.Millisecond}
+		require.NoError(t, kind.Install())
+		assert.Equal(t, 3, attempts)
+		content, err := os.ReadFile(filepath.Join(kind.Dir, "bin", "kind-v0.22.0"))
+		require.NoError(t, err)
+		assert.Equal(t, "kind", string(content))
+	})
+
+	t.Run("when all retries fail", func(t *testing.T) {
+		attempts := 0
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "v0.22.0", HTTPClient: flakyClient(10, &attempts), InstallRetries: 2, InstallBackoff: time.Millisecond}
+		err := kind.Install()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connection reset by peer")
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("when retries are disabled", func(t *testing.T) {
+		attempts := 0
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "v0.22.0", HTTPClient: flakyClient(10, &attempts), InstallRetries: -1, InstallBackoff: time.Hour}
+		err := kind.Install()
+		assert.ErrorContains(t, err, "after 1 attempts")
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("the default number of retries is used when unset", func(t *testing.T) {
+		attempts := 0
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "v0.22.0", HTTPClient: flakyClient(10, &attempts), InstallBackoff: time.Millisecond}
+		require.Error(t, kind.Install())
+		assert.Equal(t, k8s.DefaultInstallRetries+1, attempts)
+	})
+}
+
+func TestKinDInstallChecksHTTPStatus(t *testing.T) {
+	kind := &k8s.KinD{Dir: t.TempDir(), Version: "v0.0.0", HTTPClient: stubHTTPClient(http.StatusNotFound, "<html>Not Found</html>")}
+	err := kind.Install()
+	require.Error(t, err)
+	assert.EqualError(t, err, "unable to download kind v0.0.0: unexpected status 404 Not Found")
+	_, err = os.Stat(filepath.Join(kind.Dir, "bin", "kind-v0.0.0"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestKinDForVersion(t *testing.T) {
+	for version, expected := range map[string]string{
+		"v1.15.3":  "v0.11.1",
+		"v1.22.15": "v0.17.0",
+		"v1.27.3":  "v0.20.0",
+		"v1.29.2":  "v0.22.0",
+		"1.30":     "v0.23.0",
+		"v1.40.0":  "v0.24.0",
+		"v0.22.0":  "v0.22.0",
+		"0.22.0":   "v0.22.0",
+		"latest":   k8s.DefaultVersion,
+	} {
+		t.Run(version, func(t *testing.T) {
+			kind := k8s.KinDForVersion(version)
+			assert.Equal(t, expected, kind.Version)
+			assert.Equal(t, k8s.DefaultKind.Dir, kind.Dir)
+		})
+	}
+
+	t.Run("the default KinD is not modified", func(t *testing.T) {
+		k8s.KinDForVersion("v1.29.2").Version = "changed"
+		assert.Equal(t, k8s.DefaultVersion, k8s.DefaultKind.Version)
+	})
+}
+
+func TestKinDDeleteAll(t *testing.T) {
+	t.Run("all clusters are deleted", func(t *testing.T) {
+		runner := &fakeCommandRunner{outputs: map[string]string{"get clusters": "first-v1.29.0\nsecond-v1.28.0\n"}}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-first-v1.29.0"), "https://127.0.0.1:6443")
+
+		require.NoError(t, kind.DeleteAll())
+		assert.Equal(t, [][]string{
+			{"get", "clusters"},
+			{"delete", "cluster", "--name", "first-v1.29.0"},
+			{"delete", "cluster", "--name", "second-v1.28.0"},
+		}, runner.calls)
+		_, err := os.Stat(filepath.Join(kind.Dir, ".kube", "config-first-v1.29.0"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("errors are aggregated", func(t *testing.T) {
+		runner := &fakeCommandRunner{
+			outputs: map[string]string{"get clusters": "first-v1.29.0\nsecond-v1.28.0\n"},
+			errors: map[string]error{
+				"delete cluster --name first-v1.29.0":  errors.New("first failed"),
+				"delete cluster --name second-v1.28.0": errors.New("second failed"),
+			},
+		}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+		err := kind.DeleteAll()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "first failed")
+		assert.Contains(t, err.Error(), "second failed")
+	})
+
+	t.Run("listing errors are returned", func(t *testing.T) {
+		runner := &fakeCommandRunner{errors: map[string]error{"get clusters": errors.New("docker is not running")}}
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: runner}
+
+		err := kind.DeleteAll()
+		assert.ErrorContains(t, err, "unable to list kind clusters: docker is not running")
+		assert.Equal(t, [][]string{{"get", "clusters"}}, runner.calls)
+	})
+}
+
+// exportingRunner writes a log file when kind export logs is run.
+type exportingRunner struct {
+	*fakeCommandRunner
+}
+
+func (r exportingRunner) Run(ctx context.Context, env []string, stdout, stderr io.Writer, name string, args ...string) error {
+	if len(args) > 2 && args[0] == "export" && args[1] == "logs" {
+		if err := os.WriteFile(filepath.Join(args[2], "kubelet.log"), []byte("kubelet failed\n"), 0600); err != nil {
+			return err
+		}
+	}
+	return r.fakeCommandRunner.Run(ctx, env, stdout, stderr, name, args...)
+}
+
+func TestKinDClusterExportLogs(t *testing.T) {
+	runner := &fakeCommandRunner{}
+	_, cluster := startFakeKinDCluster(t, runner)
+
+	require.NoError(t, cluster.ExportLogs("/tmp/logs"))
+	assert.Equal(t, []string{"export", "logs", "/tmp/logs", "--name", "test-v1.29.0"}, runner.calls[len(runner.calls)-1])
+}
+
+func TestKinDStartExportsLogsOnCreationFailure(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	runner := &fakeCommandRunner{errors: map[string]error{}}
+	output := &strings.Builder{}
+	kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: exportingRunner{runner}, LogsOutput: output}
+	installFakeKinD(t, kind, "exit 1")
+	runner.errors["create cluster --image kindest/node:v1.29.0 --name broken-v1.29.0 --kubeconfig "+filepath.Join(kind.Dir, ".kube", "config-broken-v1.29.0")] = errors.New("exit status 1")
+
+	_, err := kind.Start("broken", "v1.29.0")
+	require.Error(t, err)
+
+	createErr := &k8s.CreateClusterError{}
+	require.ErrorAs(t, err, &createErr)
+	t.Cleanup(func() { os.RemoveAll(createErr.LogsDir) })
+	assert.Equal(t, "broken-v1.29.0", createErr.Cluster)
+	require.NotEmpty(t, createErr.LogsDir)
+	assert.Equal(t, []string{"export", "logs", createErr.LogsDir, "--name", "broken-v1.29.0"}, runner.calls[len(runner.calls)-1])
+	assert.FileExists(t, filepath.Join(createErr.LogsDir, "kubelet.log"))
+	assert.Contains(t, output.String(), "kubelet failed")
+}
+
+func TestKinDStartMinReadyPods(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	pod := func(name string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system"},
+			Status:     v1.PodStatus{Phase: v1.PodRunning},
+		}
+	}
+	server := newDiscoveryServer(t, map[string]interface{}{
+		"/api/v1/nodes": v1.NodeList{
+			TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"},
+			Items: []v1.Node{{
+				ObjectMeta: metav1.ObjectMeta{Name: "control-plane"},
+				Status: v1.NodeStatus{
+					Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+				},
+			}},
+		},
+		"/api/v1/pods": v1.PodList{
+			TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"},
+			Items:    []v1.Pod{pod("etcd"), pod("kube-apiserver")},
+		},
+	})
+	start := func(t *testing.T, minReadyPods int, timeout time.Duration) error {
+		kind := &k8s.KinD{Dir: t.TempDir(), Version: "fake", Runner: &fakeCommandRunner{}, MinReadyPods: minReadyPods}
+		installFakeKinD(t, kind, "exit 1")
+		writeKinDKubeConfig(t, filepath.Join(kind.Dir, ".kube", "config-test-v1.29.0"), server.URL)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		_, err := kind.StartContext(ctx, "test", "v1.29.0")
+		return err
+	}
+
+	t.Run("when enough pods are running", func(t *testing.T) {
+		assert.NoError(t, start(t, 2, 10*time.Second))
+	})
+
+	t.Run("when not enough pods are running", func(t *testing.T) {
+		assert.ErrorIs(t, start(t, 3, time.Second), context.DeadlineExceeded)
+	})
+
+	t.Run("the default KinD keeps the historical threshold", func(t *testing.T) {
+		assert.Equal(t, 8, k8s.DefaultKind.MinReadyPods)
+		assert.Equal(t, 8, k8s.KinDForVersion("v1.29.2").MinReadyPods)
+	})
+}
@@ -0,0 +1,31 @@
+package k8s
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ListPaged lists objects in pages of at most pageSize items, calling fn with list filled with each page.
+// list is reused across pages, so fn must not keep references to it.
+// Without a namespace option, objects are listed across all namespaces.
+// Listing stops at the first error returned by the client or by fn.
+func ListPaged(ctx context.Context, c client.Client, list client.ObjectList, pageSize int64, fn func(client.ObjectList) error, opts ...client.ListOption) error {
+	continueToken := ""
+	for {
+		pageOpts := append([]client.ListOption{client.Limit(pageSize)}, opts...)
+		if continueToken != "" {
+			pageOpts = append(pageOpts, client.Continue(continueToken))
+		}
+		if err := c.List(ctx, list, pageOpts...); err != nil {
+			return err
+		}
+		if err := fn(list); err != nil {
+			return err
+		}
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			return nil
+		}
+	}
+}
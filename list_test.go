@@ -0,0 +1,74 @@
+package k8s_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newPagingClient returns a client serving count config maps in pages, as the API server would, recording the
+// options of each List call.
+func newPagingClient(count int, calls *[]client.ListOptions) client.Client {
+	return fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			options := (&client.ListOptions{}).ApplyOptions(opts)
+			*calls = append(*calls, *options)
+			start := 0
+			if options.Continue != "" {
+				start, _ = strconv.Atoi(options.Continue)
+			}
+			end := start + int(options.Limit)
+			configMaps := list.(*v1.ConfigMapList)
+			configMaps.Items = nil
+			configMaps.Continue = ""
+			for i := start; i < end && i < count; i++ {
+				configMaps.Items = append(configMaps.Items, v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-" + strconv.Itoa(i)}})
+			}
+			if end < count {
+				configMaps.Continue = strconv.Itoa(end)
+			}
+			return nil
+		},
+	}).Build()
+}
+
+func TestListPaged(t *testing.T) {
+	t.Run("all pages are listed", func(t *testing.T) {
+		calls := []client.ListOptions{}
+		names := []string{}
+		err := k8s.ListPaged(context.Background(), newPagingClient(5, &calls), &v1.ConfigMapList{}, 2, func(list client.ObjectList) error {
+			for _, cm := range list.(*v1.ConfigMapList).Items {
+				names = append(names, cm.Name)
+			}
+			return nil
+		}, client.InNamespace("default"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"cm-0", "cm-1", "cm-2", "cm-3", "cm-4"}, names)
+		require.Len(t, calls, 3)
+		for i, continueToken := range []string{"", "2", "4"} {
+			assert.Equal(t, int64(2), calls[i].Limit)
+			assert.Equal(t, continueToken, calls[i].Continue)
+			assert.Equal(t, "default", calls[i].Namespace)
+		}
+	})
+
+	t.Run("listing stops on callback errors", func(t *testing.T) {
+		calls := []client.ListOptions{}
+		callbackErr := errors.New("stop")
+		err := k8s.ListPaged(context.Background(), newPagingClient(5, &calls), &v1.ConfigMapList{}, 2, func(list client.ObjectList) error {
+			return callbackErr
+		})
+		assert.ErrorIs(t, err, callbackErr)
+		assert.Len(t, calls, 1)
+	})
+}
@@ -1,33 +1,50 @@
 package k8s
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
+	system "github.com/adevinta/go-system-toolkit"
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/kubectl/pkg/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
 type ParseError struct {
-	Data []byte
-	Err  error
+	// Index is the position of the failing document in the stream, ignoring comment-only documents
+	Index int
+	Data  []byte
+	Err   error
 }
 
 func (p *ParseError) Error() string {
 	return fmt.Sprintf("error parsing data %s: %v", string(p.Data), p.Err.Error())
 }
 
+func (p *ParseError) Unwrap() error {
+	return p.Err
+}
+
 func commentOnly(d []byte) bool {
 	for _, b := range bytes.Split(d, []byte("\n")) {
-		line := strings.TrimPrefix(string(b), " ")
+		line := strings.TrimSpace(string(b))
 		if line != "" && !strings.HasPrefix(line, "#") {
 			return false
 		}
@@ -35,6 +52,26 @@ func commentOnly(d []byte) bool {
 	return true
 }
 
+// trimDocumentMarker removes the leading `---` document marker line, if any
+func trimDocumentMarker(d []byte) []byte {
+	rest, ok := bytes.CutPrefix(d, []byte("---"))
+	if !ok {
+		return d
+	}
+	switch {
+	case len(rest) == 0:
+		return rest
+	case rest[0] == '\n':
+		return rest[1:]
+	case bytes.HasPrefix(rest, []byte("\r\n")):
+		return rest[2:]
+	case rest[0] == ' ' || rest[0] == '\t':
+		return rest
+	}
+	// not a document marker, like `----`
+	return d
+}
+
 func ParseUnstructured(r io.Reader) ([]*unstructured.Unstructured, error) {
 	objects, err := ParseKubernetesObjects(r, &unstructured.Unstructured{})
 	if err != nil {
@@ -51,65 +88,500 @@ func ParseUnstructured(r io.Reader) ([]*unstructured.Unstructured, error) {
 	return ret, nil
 }
 
+// ParseUnstructuredValidated parses the stream like ParseUnstructured and ensures the kind of every object is
+// registered in scheme, or in the kubectl default scheme when scheme is nil.
+// The first object of an unknown kind is reported as a *ParseError.
+func ParseUnstructuredValidated(r io.Reader, scheme *runtime.Scheme) ([]*unstructured.Unstructured, error) {
+	objects, err := ParseUnstructured(r)
+	if err != nil {
+		return nil, err
+	}
+	scheme = schemeOrDefault(scheme)
+	for i, o := range objects {
+		gvk := o.GroupVersionKind()
+		if scheme.Recognizes(gvk) {
+			continue
+		}
+		data, err := o.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return nil, &ParseError{Index: i, Data: data, Err: runtime.NewNotRegisteredErrForKind(scheme.Name(), gvk)}
+	}
+	return objects, nil
+}
+
+// ParseSingleUnstructured parses a stream that must contain exactly one Kubernetes object.
+func ParseSingleUnstructured(r io.Reader) (*unstructured.Unstructured, error) {
+	objects, err := ParseUnstructured(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) != 1 {
+		return nil, fmt.Errorf("expected exactly one object, found %d", len(objects))
+	}
+	return objects[0], nil
+}
+
+// ParseUnstructuredFromFile parses the Kubernetes objects defined in the given file.
+func ParseUnstructuredFromFile(path string) ([]*unstructured.Unstructured, error) {
+	fd, err := system.DefaultFileSystem.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return ParseUnstructured(fd)
+}
+
+func isManifestFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// ParseUnstructuredFromDir recursively parses the Kubernetes objects defined in the .yaml, .yml and .json files of
+// the given directory. Files are parsed in lexical order.
+func ParseUnstructuredFromDir(dir string) ([]*unstructured.Unstructured, error) {
+	objects := []*unstructured.Unstructured{}
+	err := afero.Walk(system.DefaultFileSystem, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isManifestFile(path) {
+			return nil
+		}
+		o, err := ParseUnstructuredFromFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		objects = append(objects, o...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ParseUnstructuredFromTarGz parses the Kubernetes objects defined in the .yaml, .yml and .json files of a
+// gzipped tar archive. Files are parsed in lexical order of their path in the archive, other files are skipped.
+func ParseUnstructuredFromTarGz(r io.Reader) ([]*unstructured.Unstructured, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || !isManifestFile(header.Name) {
+			continue
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Name, err)
+		}
+		files[header.Name] = data
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	objects := []*unstructured.Unstructured{}
+	for _, name := range names {
+		o, err := ParseUnstructured(bytes.NewReader(files[name]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		objects = append(objects, o...)
+	}
+	return objects, nil
+}
+
 func ParseKubernetesObjects(r io.Reader, as runtime.Object) ([]runtime.Object, error) {
+	return ParseKubernetesObjectsWithScheme(r, scheme.Scheme, as)
+}
+
+// ParseKubernetesObjectsWithScheme parses Kubernetes objects, decoding them to the types registered in the provided scheme.
+func ParseKubernetesObjectsWithScheme(r io.Reader, scheme *runtime.Scheme, as runtime.Object) ([]runtime.Object, error) {
 	objects := []runtime.Object{}
-	kubereader := kubeyaml.NewYAMLReader(bufio.NewReader(r))
-	for {
+	var parseErr error
+	decodeDocuments(r, serializer.NewCodecFactory(scheme).UniversalDeserializer(), as, func(o runtime.Object, err error) bool {
+		if err != nil {
+			parseErr = err
+			return false
+		}
+		objects = append(objects, o)
+		return true
+	})
+	if parseErr != nil {
+		return []runtime.Object{}, parseErr
+	}
+	return objects, nil
+}
+
+// ParseKubernetesObjectsLenient parses Kubernetes objects, carrying on past documents that fail to decode.
+// The successfully parsed objects are returned along with an error aggregating every *ParseError.
+func ParseKubernetesObjectsLenient(r io.Reader, as runtime.Object) ([]runtime.Object, error) {
+	objects := []runtime.Object{}
+	errs := []error{}
+	decodeDocuments(r, scheme.Codecs.UniversalDeserializer(), as, func(o runtime.Object, err error) bool {
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				errs = append(errs, err)
+				return false
+			}
+			errs = append(errs, fmt.Errorf("document %d: %w", parseErr.Index, err))
+			return true
+		}
+		objects = append(objects, o)
+		return true
+	})
+	return objects, errors.Join(errs...)
+}
+
+// ParseUnstructuredStream lazily parses Kubernetes objects, one document at a time.
+// Parsing stops as soon as yield returns false or after the first error.
+// The returned function has the iter.Seq2 signature so it can be ranged over with go >= 1.23.
+func ParseUnstructuredStream(r io.Reader) func(yield func(*unstructured.Unstructured, error) bool) {
+	return func(yield func(*unstructured.Unstructured, error) bool) {
+		decodeDocuments(r, scheme.Codecs.UniversalDeserializer(), &unstructured.Unstructured{}, func(o runtime.Object, err error) bool {
+			if err != nil {
+				yield(nil, err)
+				return false
+			}
+			u, ok := o.(*unstructured.Unstructured)
+			if !ok {
+				yield(nil, fmt.Errorf("unexpected type %T expecting *unstructured.Unstructured", o))
+				return false
+			}
+			return yield(u, nil)
+		})
+	}
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// DecodeInto decodes a single JSON or YAML object from data into into, using the types registered in scheme.
+// The kubectl default scheme is used when scheme is nil.
+// Decoding failures are returned as *ParseError.
+func DecodeInto(data []byte, scheme *runtime.Scheme, into runtime.Object) error {
+	decoder := serializer.NewCodecFactory(schemeOrDefault(scheme)).UniversalDeserializer()
+	o, _, err := decoder.Decode(data, nil, into)
+	if err != nil {
+		return &ParseError{Data: data, Err: err}
+	}
+	if o != into {
+		return &ParseError{Data: data, Err: fmt.Errorf("decoded type %T does not match the expected type %T", o, into)}
+	}
+	return nil
+}
+
+func decodeDocuments(r io.Reader, decoder runtime.Decoder, as runtime.Object, yield func(runtime.Object, error) bool) {
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+	kubereader := kubeyaml.NewYAMLReader(reader)
+	for index := 0; ; {
 		data, err := kubereader.Read()
 		if err != nil {
-			if err == io.EOF {
-				break
+			if err != io.EOF {
+				yield(nil, err)
 			}
-			return []runtime.Object{}, err
+			return
 		}
-		data = bytes.TrimLeft(data, "---")
-		if !commentOnly(data) {
-			if as != nil {
-				as = as.DeepCopyObject()
+		// JSON documents are left untouched: they can neither start with a document marker nor
+		// have lines starting with #, strings can not span several lines.
+		data = trimDocumentMarker(data)
+		if commentOnly(data) {
+			continue
+		}
+		if as != nil {
+			as = as.DeepCopyObject()
+		}
+		o, _, err := decoder.Decode(data, nil, as)
+		if err != nil {
+			if !yield(nil, &ParseError{
+				Index: index,
+				Data:  data,
+				Err:   err,
+			}) {
+				return
 			}
-			o, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, as)
+		} else if !yield(o, nil) {
+			return
+		}
+		index++
+	}
+}
+
+// SerialiseOptions controls how SerialiseObjectsWithOptions writes objects.
+type SerialiseOptions struct {
+	// LeadingSeparator writes a document separator before the first object too
+	LeadingSeparator bool
+	// JSON writes objects as compact JSON, one per line, instead of YAML documents.
+	// No document separator is written in this mode.
+	JSON bool
+	// OmitEmpty drops null values, like `creationTimestamp: null`, and server defaulted empty fields, like
+	// `status: {}` or `strategy: {}`, to produce cleaner manifests.
+	// Meaningful empty maps, like `emptyDir: {}` or `podSelector: {}`, are kept.
+	OmitEmpty bool
+}
+
+func SerialiseObjects(scheme *runtime.Scheme, w io.Writer, objects ...runtime.Object) error {
+	return SerialiseObjectsWithOptions(scheme, w, SerialiseOptions{}, objects...)
+}
+
+func SerialiseObjectsWithOptions(scheme *runtime.Scheme, w io.Writer, opts SerialiseOptions, objects ...runtime.Object) error {
+	encoder := serializer.NewCodecFactory(scheme).WithoutConversion().EncoderForVersion(
+		json.NewSerializerWithOptions(
+			json.DefaultMetaFactory,
+			scheme,
+			scheme,
+			json.SerializerOptions{
+				Yaml:   !opts.JSON,
+				Strict: true,
+				Pretty: !opts.JSON,
+			}),
+		nil,
+	)
+	for i, o := range objects {
+		if !opts.JSON && (i > 0 || opts.LeadingSeparator) {
+			_, err := w.Write([]byte("---\n"))
+			if err != nil {
+				return err
+			}
+		}
+		if opts.OmitEmpty {
+			var err error
+			o, err = withoutEmptyFields(scheme, o)
 			if err != nil {
-				return []runtime.Object{}, &ParseError{
-					Data: data,
-					Err:  err,
+				return err
+			}
+		}
+		err := encoder.Encode(o, w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withoutEmptyFields returns an unstructured copy of o without its null values and server defaulted empty fields.
+func withoutEmptyFields(scheme *runtime.Scheme, o runtime.Object) (runtime.Object, error) {
+	if u, ok := o.(*unstructured.Unstructured); ok {
+		u = u.DeepCopy()
+		pruneEmptyFields(u.Object)
+		return u, nil
+	}
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+	if err != nil {
+		return nil, err
+	}
+	gvks, _, err := scheme.ObjectKinds(o)
+	if err != nil {
+		return nil, err
+	}
+	pruneEmptyFields(data)
+	u := &unstructured.Unstructured{Object: data}
+	u.SetGroupVersionKind(gvks[0])
+	return u, nil
+}
+
+// omittedEmptyFields are the fields holding server defaulted or meaningless empty maps, like `strategy: {}`,
+// dropped at any depth by OmitEmpty. Other empty maps, like `emptyDir: {}`, carry meaning and are kept.
+var omittedEmptyFields = map[string]struct{}{
+	"metadata":       {},
+	"labels":         {},
+	"annotations":    {},
+	"resources":      {},
+	"strategy":       {},
+	"updateStrategy": {},
+}
+
+// pruneEmptyFields removes, in place, the null values, the empty omittedEmptyFields and the empty status of obj.
+func pruneEmptyFields(obj map[string]interface{}) {
+	pruneNestedEmptyFields(obj)
+	if status, ok := obj["status"].(map[string]interface{}); ok && len(status) == 0 {
+		delete(obj, "status")
+	}
+}
+
+func pruneNestedEmptyFields(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if field == nil {
+				delete(v, key)
+				continue
+			}
+			pruneNestedEmptyFields(field)
+			if m, ok := field.(map[string]interface{}); ok && len(m) == 0 {
+				if _, ok := omittedEmptyFields[key]; ok {
+					delete(v, key)
 				}
 			}
-			objects = append(objects, o)
+		}
+	case []interface{}:
+		for _, item := range v {
+			pruneNestedEmptyFields(item)
 		}
 	}
-	return objects, nil
 }
 
-func SerialiseObjects(scheme *runtime.Scheme, w io.Writer, objects ...runtime.Object) error {
-	for i, o := range objects {
-		if i > 0 {
-			w.Write([]byte("---\n"))
-		}
-		err := serializer.NewCodecFactory(scheme).WithoutConversion().EncoderForVersion(
-			json.NewSerializerWithOptions(
-				json.DefaultMetaFactory,
-				scheme,
-				scheme,
-				json.SerializerOptions{
-					Yaml:   true,
-					Strict: true,
-					Pretty: true,
-				}),
-			nil,
-		).Encode(o, w)
+type sortedObject struct {
+	obj       runtime.Object
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// SerialiseSorted writes objects like SerialiseObjects, in a stable order: following DefaultApplyPriority, then by
+// apiVersion, kind, namespace and name.
+// The objects slice is not modified.
+func SerialiseSorted(scheme *runtime.Scheme, w io.Writer, objects ...runtime.Object) error {
+	sorted := make([]sortedObject, 0, len(objects))
+	for _, o := range objects {
+		gvk, err := apiutil.GVKForObject(o, scheme)
 		if err != nil {
 			return err
 		}
+		accessor, err := meta.Accessor(o)
+		if err != nil {
+			return err
+		}
+		sorted = append(sorted, sortedObject{obj: o, gvk: gvk, namespace: accessor.GetNamespace(), name: accessor.GetName()})
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if pa, pb := kindPriority(a.gvk.Kind), kindPriority(b.gvk.Kind); pa != pb {
+			return pa < pb
+		}
+		if va, vb := a.gvk.GroupVersion().String(), b.gvk.GroupVersion().String(); va != vb {
+			return va < vb
+		}
+		if a.gvk.Kind != b.gvk.Kind {
+			return a.gvk.Kind < b.gvk.Kind
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		return a.name < b.name
+	})
+	r := make([]runtime.Object, 0, len(sorted))
+	for _, o := range sorted {
+		r = append(r, o.obj)
+	}
+	return SerialiseObjects(scheme, w, r...)
+}
+
+// SerialiseObjectsToDir writes each object as YAML to its own file of dir, named after its lowercased kind,
+// its API group unless it is the core one, its namespace when set, and its name, like
+// deployment.apps-default-my-app.yaml or namespace-default.yaml.
+// The directory is created when missing, and existing files are replaced.
+// Nothing is written when several objects would be written to the same file.
+func SerialiseObjectsToDir(scheme *runtime.Scheme, dir string, objects ...runtime.Object) error {
+	files := make(map[string]runtime.Object, len(objects))
+	names := make([]string, 0, len(objects))
+	for _, o := range objects {
+		gvk, err := apiutil.GVKForObject(o, scheme)
+		if err != nil {
+			return err
+		}
+		accessor, err := meta.Accessor(o)
+		if err != nil {
+			return err
+		}
+		name := objectFileName(gvk, accessor.GetNamespace(), accessor.GetName())
+		if _, ok := files[name]; ok {
+			return fmt.Errorf("several objects would be written to %s, including %s %s/%s", name, gvk.Kind, accessor.GetNamespace(), accessor.GetName())
+		}
+		files[name] = o
+		names = append(names, name)
+	}
+	if err := system.DefaultFileSystem.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		b := bytes.Buffer{}
+		if err := SerialiseObjects(scheme, &b, files[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := afero.WriteFile(system.DefaultFileSystem, filepath.Join(dir, name), b.Bytes(), 0644); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+func objectFileName(gvk schema.GroupVersionKind, namespace, name string) string {
+	parts := []string{strings.ToLower(gvk.Kind)}
+	if gvk.Group != "" {
+		parts[0] += "." + gvk.Group
+	}
+	if namespace != "" {
+		parts = append(parts, namespace)
+	}
+	return strings.Join(append(parts, name), "-") + ".yaml"
+}
+
+// ToUnstructured converts objects to their unstructured representation, like ToUnstructuredObjects.
 func ToUnstructured(scheme *runtime.Scheme, objects ...client.Object) ([]*unstructured.Unstructured, error) {
+	runtimeObjects := make([]runtime.Object, 0, len(objects))
+	for _, obj := range objects {
+		runtimeObjects = append(runtimeObjects, obj)
+	}
+	return ToUnstructuredObjects(scheme, runtimeObjects...)
+}
+
+// ToUnstructuredObjects converts objects to their unstructured representation.
+// Lists, typed or unstructured, are expanded into one unstructured object per item.
+// The kubectl default scheme, knowing the built-in types, is used when scheme is nil.
+// The returned objects never share memory with the inputs.
+func ToUnstructuredObjects(scheme *runtime.Scheme, objects ...runtime.Object) ([]*unstructured.Unstructured, error) {
+	scheme = schemeOrDefault(scheme)
 	unstructuredObjects := []*unstructured.Unstructured{}
 	for _, obj := range objects {
+		if u, ok := obj.(*unstructured.Unstructured); ok && u.IsList() {
+			list, err := u.ToList()
+			if err != nil {
+				return nil, err
+			}
+			obj = list
+		}
+		if meta.IsListType(obj) {
+			items, err := meta.ExtractList(obj)
+			if err != nil {
+				return nil, err
+			}
+			expanded, err := ToUnstructuredObjects(scheme, items...)
+			if err != nil {
+				return nil, err
+			}
+			unstructuredObjects = append(unstructuredObjects, expanded...)
+			continue
+		}
 		switch o := obj.(type) {
 		case *unstructured.Unstructured:
-			unstructuredObjects = append(unstructuredObjects, o)
+			unstructuredObjects = append(unstructuredObjects, o.DeepCopy())
 		default:
 			data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
 			if err != nil {
@@ -133,6 +605,49 @@ func ToUnstructured(scheme *runtime.Scheme, objects ...client.Object) ([]*unstru
 	return unstructuredObjects, nil
 }
 
+// FromUnstructuredOptions controls how FromUnstructuredWithOptions converts objects.
+type FromUnstructuredOptions struct {
+	// KeepUnregistered returns a copy of the objects whose kind is not registered in the scheme, instead of failing
+	KeepUnregistered bool
+}
+
+// FromUnstructured converts unstructured objects to their typed representation, using the types registered in
+// scheme, or in the kubectl default scheme when scheme is nil.
+// It fails on objects whose kind is not registered in the scheme.
+func FromUnstructured(scheme *runtime.Scheme, objs ...*unstructured.Unstructured) ([]runtime.Object, error) {
+	return FromUnstructuredWithOptions(scheme, FromUnstructuredOptions{}, objs...)
+}
+
+// FromUnstructuredWithOptions converts unstructured objects to their typed representation, like FromUnstructured.
+func FromUnstructuredWithOptions(scheme *runtime.Scheme, opts FromUnstructuredOptions, objs ...*unstructured.Unstructured) ([]runtime.Object, error) {
+	scheme = schemeOrDefault(scheme)
+	objects := make([]runtime.Object, 0, len(objs))
+	for _, u := range objs {
+		gvk := u.GroupVersionKind()
+		obj, err := scheme.New(gvk)
+		if err != nil {
+			if runtime.IsNotRegisteredError(err) && opts.KeepUnregistered {
+				objects = append(objects, u.DeepCopy())
+				continue
+			}
+			return nil, err
+		}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert %s %s/%s: %w", gvk.Kind, u.GetNamespace(), u.GetName(), err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func schemeOrDefault(s *runtime.Scheme) *runtime.Scheme {
+	if s == nil {
+		return scheme.Scheme
+	}
+	return s
+}
+
 func ToClientObject(unstructuredObjects []*unstructured.Unstructured) []client.Object {
 	r := []client.Object{}
 	for _, o := range unstructuredObjects {
@@ -140,3 +655,12 @@ func ToClientObject(unstructuredObjects []*unstructured.Unstructured) []client.O
 	}
 	return r
 }
+
+// ConvertToVersion converts the object to the given group version using the conversions registered in the scheme.
+func ConvertToVersion(scheme *runtime.Scheme, obj runtime.Object, gv schema.GroupVersion) (runtime.Object, error) {
+	o, err := scheme.ConvertToVersion(obj, gv)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert %T to %s: %w", obj, gv.String(), err)
+	}
+	return o, nil
+}
@@ -1,17 +1,27 @@
 package k8s_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
 	"strings"
 	"testing"
 
 	k8s "github.com/adevinta/go-k8s-toolkit"
+	system "github.com/adevinta/go-system-toolkit"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/conversion"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -308,3 +318,784 @@ func TestToUnstructured(t *testing.T) {
 		objects,
 	)
 }
+
+type CustomSpec struct {
+	Replicas int `json:"replicas"`
+}
+
+type Custom struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              CustomSpec `json:"spec"`
+}
+
+func (c *Custom) DeepCopyObject() runtime.Object {
+	r := *c
+	c.ObjectMeta.DeepCopyInto(&r.ObjectMeta)
+	return &r
+}
+
+func TestParseObjectsWithScheme(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(schema.GroupVersion{Group: "custom.testing.ltd", Version: "v1"}, &Custom{})
+
+	o, err := k8s.ParseKubernetesObjectsWithScheme(strings.NewReader(`
+apiVersion: custom.testing.ltd/v1
+kind: Custom
+metadata:
+  name: my-custom
+  namespace: my-namespace
+spec:
+  replicas: 3
+`), scheme, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []runtime.Object{
+		&Custom{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Custom",
+				APIVersion: "custom.testing.ltd/v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-custom",
+				Namespace: "my-namespace",
+			},
+			Spec: CustomSpec{Replicas: 3},
+		},
+	}, o)
+}
+
+// endlessManifests produces an infinite stream of ConfigMap documents
+type endlessManifests struct {
+	buf bytes.Buffer
+	n   int
+}
+
+func (e *endlessManifests) Read(p []byte) (int, error) {
+	for e.buf.Len() < len(p) {
+		fmt.Fprintf(&e.buf, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm-%d\n", e.n)
+		e.n++
+	}
+	return e.buf.Read(p)
+}
+
+func TestParseUnstructuredStream(t *testing.T) {
+	t.Run("all objects are yielded in order", func(t *testing.T) {
+		names := []string{}
+		k8s.ParseUnstructuredStream(strings.NewReader(testObjects))(func(o *unstructured.Unstructured, err error) bool {
+			require.NoError(t, err)
+			names = append(names, o.GetName())
+			return true
+		})
+		assert.Equal(t, []string{"some-name", "pod-name"}, names)
+	})
+	t.Run("the caller can stop before the end of the stream", func(t *testing.T) {
+		input := &endlessManifests{}
+		objects := []*unstructured.Unstructured{}
+		k8s.ParseUnstructuredStream(input)(func(o *unstructured.Unstructured, err error) bool {
+			require.NoError(t, err)
+			objects = append(objects, o)
+			return false
+		})
+		require.Len(t, objects, 1)
+		assert.Equal(t, "cm-0", objects[0].GetName())
+	})
+	t.Run("parse errors are yielded", func(t *testing.T) {
+		var errs []error
+		k8s.ParseUnstructuredStream(strings.NewReader("apiVersion: v1\nkind: [\n"))(func(o *unstructured.Unstructured, err error) bool {
+			assert.Nil(t, o)
+			errs = append(errs, err)
+			return true
+		})
+		require.Len(t, errs, 1)
+		assert.Error(t, errs[0])
+	})
+	t.Run("parsing stops after the first error", func(t *testing.T) {
+		var names []string
+		var errs []error
+		input := strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: first\n---\nkind: [\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: last\n")
+		k8s.ParseUnstructuredStream(input)(func(o *unstructured.Unstructured, err error) bool {
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				names = append(names, o.GetName())
+			}
+			return true
+		})
+		assert.Equal(t, []string{"first"}, names)
+		require.Len(t, errs, 1)
+		var parseErr *k8s.ParseError
+		assert.ErrorAs(t, errs[0], &parseErr)
+	})
+}
+
+func TestParseObjectsLenient(t *testing.T) {
+	o, err := k8s.ParseKubernetesObjectsLenient(strings.NewReader(`
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: first
+---
+# invalid document
+apiVersion: v1
+metadata:
+  name: no-kind
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+  namespace: first
+---
+apiVersion: v1
+kind: Secret
+metadata: [
+`), &unstructured.Unstructured{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "document 1: ")
+	assert.Contains(t, err.Error(), "document 3: ")
+	var parseErr *k8s.ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 1, parseErr.Index)
+
+	require.Len(t, o, 2)
+	assert.Equal(t, "first", o[0].(*unstructured.Unstructured).GetName())
+	assert.Equal(t, "second", o[1].(*unstructured.Unstructured).GetName())
+}
+
+func TestParseUnstructuredFromFile(t *testing.T) {
+	t.Cleanup(system.Reset)
+	system.DefaultFileSystem = afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(system.DefaultFileSystem, "/manifests/objects.yaml", []byte(testObjects), 0644))
+
+	o, err := k8s.ParseUnstructuredFromFile("/manifests/objects.yaml")
+	require.NoError(t, err)
+	require.Len(t, o, 2)
+	assert.Equal(t, "some-name", o[0].GetName())
+	assert.Equal(t, "pod-name", o[1].GetName())
+
+	_, err = k8s.ParseUnstructuredFromFile("/manifests/missing.yaml")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestParseUnstructuredFromDir(t *testing.T) {
+	t.Cleanup(system.Reset)
+	system.DefaultFileSystem = afero.NewMemMapFs()
+	files := map[string]string{
+		"/manifests/b.yml":              "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n",
+		"/manifests/a.yaml":             "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+		"/manifests/nested/c.json":      `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "c"}}`,
+		"/manifests/nested/deep/d.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: d\n",
+		"/manifests/README.md":          "# not a manifest",
+	}
+	for path, content := range files {
+		require.NoError(t, afero.WriteFile(system.DefaultFileSystem, path, []byte(content), 0644))
+	}
+
+	o, err := k8s.ParseUnstructuredFromDir("/manifests")
+	require.NoError(t, err)
+	names := []string{}
+	for _, u := range o {
+		names = append(names, u.GetName())
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, names)
+}
+
+func TestParseUnstructuredFromTarGz(t *testing.T) {
+	archive := bytes.Buffer{}
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	// entries are written out of order to check they are sorted
+	for _, file := range []struct{ name, content string }{
+		{"manifests/b.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"},
+		{"README.md", "# not a manifest"},
+		{"manifests/a.json", `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}`},
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(file.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	o, err := k8s.ParseUnstructuredFromTarGz(&archive)
+	require.NoError(t, err)
+	names := []string{}
+	for _, u := range o {
+		names = append(names, u.GetName())
+	}
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	_, err = k8s.ParseUnstructuredFromTarGz(strings.NewReader("not an archive"))
+	assert.Error(t, err)
+}
+
+func TestSerialiseObjectsToDir(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	t.Run("each object is written to its own file", func(t *testing.T) {
+		t.Cleanup(system.Reset)
+		system.DefaultFileSystem = afero.NewMemMapFs()
+		require.NoError(t, k8s.SerialiseObjectsToDir(
+			scheme,
+			"/manifests",
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "default"}},
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "default"}, Data: map[string]string{"hello": "world"}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		))
+
+		entries, err := afero.ReadDir(system.DefaultFileSystem, "/manifests")
+		require.NoError(t, err)
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{"configmap-default-my-app.yaml", "deployment.apps-default-my-app.yaml", "namespace-default.yaml"}, names)
+
+		o, err := k8s.ParseUnstructuredFromFile("/manifests/configmap-default-my-app.yaml")
+		require.NoError(t, err)
+		require.Len(t, o, 1)
+		assert.Equal(t, "ConfigMap", o[0].GetKind())
+		assert.Equal(t, "my-app", o[0].GetName())
+		assert.Equal(t, "default", o[0].GetNamespace())
+		assert.Equal(t, map[string]interface{}{"hello": "world"}, o[0].Object["data"])
+	})
+
+	t.Run("objects sharing a name in different namespaces are written to different files", func(t *testing.T) {
+		t.Cleanup(system.Reset)
+		system.DefaultFileSystem = afero.NewMemMapFs()
+		require.NoError(t, k8s.SerialiseObjectsToDir(
+			scheme,
+			"/manifests",
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "a"}},
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "b"}},
+		))
+		for _, name := range []string{"configmap-a-config.yaml", "configmap-b-config.yaml"} {
+			exists, err := afero.Exists(system.DefaultFileSystem, "/manifests/"+name)
+			require.NoError(t, err)
+			assert.True(t, exists, name)
+		}
+	})
+
+	t.Run("colliding objects return an error", func(t *testing.T) {
+		t.Cleanup(system.Reset)
+		system.DefaultFileSystem = afero.NewMemMapFs()
+		err := k8s.SerialiseObjectsToDir(
+			scheme,
+			"/manifests",
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "a"}},
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "a"}},
+		)
+		assert.ErrorContains(t, err, "configmap-a-config.yaml")
+		exists, err := afero.DirExists(system.DefaultFileSystem, "/manifests")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestParseJSONDocumentsInYAMLStream(t *testing.T) {
+	objects, err := k8s.ParseUnstructured(strings.NewReader("apiVersion: v1\n" +
+		"kind: ConfigMap\n" +
+		"metadata:\n" +
+		"  name: yaml\n" +
+		"---\n" +
+		"{\n" +
+		"\t\"apiVersion\": \"v1\",\n" +
+		"\t\"kind\": \"ConfigMap\",\n" +
+		"\t\"metadata\": {\"name\": \"json\"},\n" +
+		"\t\"data\": {\"script\": \"#!/bin/sh\\n--- not a separator\\n# not a comment\"}\n" +
+		"}\n" +
+		"---\n" +
+		"# generated by a tool\n" +
+		"{\"apiVersion\": \"v1\", \"kind\": \"ConfigMap\", \"metadata\": {\"name\": \"commented-json\"}}\n",
+	))
+	require.NoError(t, err)
+	names := []string{}
+	for _, o := range objects {
+		names = append(names, o.GetName())
+	}
+	assert.Equal(t, []string{"yaml", "json", "commented-json"}, names)
+	assert.Equal(t, map[string]interface{}{"script": "#!/bin/sh\n--- not a separator\n# not a comment"}, objects[1].Object["data"])
+}
+
+func TestSerializeObjectsWithLeadingSeparator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	objects := []runtime.Object{
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "first"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "second"}},
+	}
+
+	d := bytes.Buffer{}
+	require.NoError(t, k8s.SerialiseObjectsWithOptions(scheme, &d, k8s.SerialiseOptions{}, objects...))
+	assert.False(t, strings.HasPrefix(d.String(), "---\n"))
+	assert.Equal(t, 1, strings.Count(d.String(), "---\n"))
+
+	d = bytes.Buffer{}
+	require.NoError(t, k8s.SerialiseObjectsWithOptions(scheme, &d, k8s.SerialiseOptions{LeadingSeparator: true}, objects...))
+	assert.True(t, strings.HasPrefix(d.String(), "---\n"))
+	assert.Equal(t, 2, strings.Count(d.String(), "---\n"))
+
+	o, err := k8s.ParseUnstructured(&d)
+	require.NoError(t, err)
+	require.Len(t, o, 2)
+}
+
+func TestSerializeObjectsOmittingEmptyFields(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	replicas := int32(0)
+	objects := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: "app", Image: "nginx"}},
+					Volumes:    []v1.Volume{{Name: "cache", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}},
+				}},
+			},
+		},
+		&networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "default"},
+			Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}},
+		},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "config", "labels": map[string]interface{}{}},
+			"data":       map[string]interface{}{"empty": ""},
+		}},
+	}
+
+	d := bytes.Buffer{}
+	require.NoError(t, k8s.SerialiseObjects(scheme, &d, objects...))
+	assert.Contains(t, d.String(), "status: {}")
+	assert.Contains(t, d.String(), "strategy: {}")
+
+	d = bytes.Buffer{}
+	require.NoError(t, k8s.SerialiseObjectsWithOptions(scheme, &d, k8s.SerialiseOptions{OmitEmpty: true}, objects...))
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+spec:
+  replicas: 0
+  template:
+    spec:
+      containers:
+      - image: nginx
+        name: app
+      volumes:
+      - emptyDir: {}
+        name: cache
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-all
+  namespace: default
+spec:
+  podSelector: {}
+  policyTypes:
+  - Ingress
+---
+apiVersion: v1
+data:
+  empty: ""
+kind: ConfigMap
+metadata:
+  name: config
+`, d.String())
+	assert.Contains(t, objects[2].(*unstructured.Unstructured).Object["metadata"], "labels", "the input objects must not be modified")
+}
+
+func TestSerializeObjectsAsJSON(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	d := bytes.Buffer{}
+	require.NoError(t, k8s.SerialiseObjectsWithOptions(
+		scheme,
+		&d,
+		k8s.SerialiseOptions{JSON: true},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-cm", Namespace: "my-namespace"},
+			Data:       map[string]string{"hello": "world"},
+		},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "my-namespace"}},
+	))
+	lines := strings.Split(strings.TrimSuffix(d.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, `{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"my-cm","namespace":"my-namespace","creationTimestamp":null},"data":{"hello":"world"}}`, lines[0])
+
+	objects := []runtime.Object{}
+	for _, line := range lines {
+		o, err := k8s.ParseKubernetesObjects(strings.NewReader(line), nil)
+		require.NoError(t, err)
+		objects = append(objects, o...)
+	}
+	require.Len(t, objects, 2)
+	require.IsType(t, &v1.ConfigMap{}, objects[0])
+	assert.Equal(t, map[string]string{"hello": "world"}, objects[0].(*v1.ConfigMap).Data)
+	require.IsType(t, &v1.Namespace{}, objects[1])
+	assert.Equal(t, "my-namespace", objects[1].(*v1.Namespace).Name)
+}
+
+func TestParseSingleUnstructured(t *testing.T) {
+	t.Run("with no document", func(t *testing.T) {
+		o, err := k8s.ParseSingleUnstructured(strings.NewReader("---\n# nothing here\n"))
+		assert.EqualError(t, err, "expected exactly one object, found 0")
+		assert.Nil(t, o)
+	})
+
+	t.Run("with one document", func(t *testing.T) {
+		o, err := k8s.ParseSingleUnstructured(strings.NewReader("---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"))
+		require.NoError(t, err)
+		require.NotNil(t, o)
+		assert.Equal(t, "ConfigMap", o.GetKind())
+		assert.Equal(t, "config", o.GetName())
+	})
+
+	t.Run("with two documents", func(t *testing.T) {
+		o, err := k8s.ParseSingleUnstructured(strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: first\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: second\n"))
+		assert.EqualError(t, err, "expected exactly one object, found 2")
+		assert.Nil(t, o)
+	})
+}
+
+func TestDecodeInto(t *testing.T) {
+	t.Run("a pod is decoded", func(t *testing.T) {
+		pod := &v1.Pod{}
+		err := k8s.DecodeInto([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"my-pod","namespace":"default"},"spec":{"containers":[{"name":"app","image":"nginx"}]}}`), nil, pod)
+		require.NoError(t, err)
+		assert.Equal(t, "my-pod", pod.Name)
+		require.Len(t, pod.Spec.Containers, 1)
+		assert.Equal(t, "nginx", pod.Spec.Containers[0].Image)
+	})
+
+	t.Run("invalid data returns a ParseError", func(t *testing.T) {
+		err := k8s.DecodeInto([]byte(`{"apiVersion":"v1","kind":"Pod",`), nil, &v1.Pod{})
+		var parseErr *k8s.ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, []byte(`{"apiVersion":"v1","kind":"Pod",`), parseErr.Data)
+	})
+
+	t.Run("mismatching types return a ParseError", func(t *testing.T) {
+		err := k8s.DecodeInto([]byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"my-cm"}}`), nil, &v1.Pod{})
+		var parseErr *k8s.ParseError
+		assert.ErrorAs(t, err, &parseErr)
+	})
+}
+
+func TestParseUnstructuredValidated(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	t.Run("registered kinds are returned", func(t *testing.T) {
+		objects, err := k8s.ParseUnstructuredValidated(strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-cm
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+`), scheme)
+		require.NoError(t, err)
+		require.Len(t, objects, 2)
+		assert.Equal(t, "my-cm", objects[0].GetName())
+		assert.Equal(t, "my-app", objects[1].GetName())
+	})
+
+	t.Run("unregistered kinds return a ParseError", func(t *testing.T) {
+		_, err := k8s.ParseUnstructuredValidated(strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-cm
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+`), scheme)
+		var parseErr *k8s.ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, 1, parseErr.Index)
+		assert.True(t, runtime.IsNotRegisteredError(parseErr.Err))
+		assert.Contains(t, string(parseErr.Data), "my-widget")
+	})
+}
+
+func TestFromUnstructured(t *testing.T) {
+	pod := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "my-pod", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "app", "image": "nginx"}},
+		},
+	}}
+	widget := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "my-widget"},
+	}}
+
+	t.Run("registered kinds are converted to typed objects", func(t *testing.T) {
+		objects, err := k8s.FromUnstructured(nil, pod)
+		require.NoError(t, err)
+		require.Len(t, objects, 1)
+		typed, ok := objects[0].(*v1.Pod)
+		require.True(t, ok, "expected a *v1.Pod, got %T", objects[0])
+		assert.Equal(t, "my-pod", typed.Name)
+		assert.Equal(t, "Pod", typed.Kind)
+		require.Len(t, typed.Spec.Containers, 1)
+		assert.Equal(t, "nginx", typed.Spec.Containers[0].Image)
+	})
+
+	t.Run("unregistered kinds fail by default", func(t *testing.T) {
+		_, err := k8s.FromUnstructured(nil, pod, widget)
+		assert.True(t, runtime.IsNotRegisteredError(err))
+	})
+
+	t.Run("unregistered kinds can be kept unstructured", func(t *testing.T) {
+		objects, err := k8s.FromUnstructuredWithOptions(nil, k8s.FromUnstructuredOptions{KeepUnregistered: true}, pod, widget)
+		require.NoError(t, err)
+		require.Len(t, objects, 2)
+		assert.IsType(t, &v1.Pod{}, objects[0])
+		assert.Equal(t, widget, objects[1])
+		assert.NotSame(t, widget, objects[1])
+	})
+}
+
+func TestSerialiseSorted(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	objects := []runtime.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "b"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "b"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "a"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "a"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "another", Namespace: "a"}},
+	}
+
+	expected := bytes.Buffer{}
+	require.NoError(t, k8s.SerialiseSorted(scheme, &expected, objects...))
+	parsed, err := k8s.ParseUnstructured(bytes.NewReader(expected.Bytes()))
+	require.NoError(t, err)
+	identities := []string{}
+	for _, o := range parsed {
+		identities = append(identities, o.GetKind()+"/"+o.GetNamespace()+"/"+o.GetName())
+	}
+	assert.Equal(t, []string{
+		"Namespace//a",
+		"Namespace//b",
+		"ConfigMap/a/another",
+		"ConfigMap/a/config",
+		"ConfigMap/b/config",
+		"Secret/a/secret",
+		"Deployment/b/app",
+	}, identities)
+
+	rand.New(rand.NewSource(42)).Shuffle(len(objects), func(i, j int) {
+		objects[i], objects[j] = objects[j], objects[i]
+	})
+	shuffled := append([]runtime.Object{}, objects...)
+	d := bytes.Buffer{}
+	require.NoError(t, k8s.SerialiseSorted(scheme, &d, objects...))
+	assert.Equal(t, expected.String(), d.String())
+	assert.Equal(t, shuffled, objects, "the input must not be sorted in place")
+}
+
+func TestToUnstructuredWithNilScheme(t *testing.T) {
+	objects, err := k8s.ToUnstructured(nil, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "my-pod", Namespace: "default"}})
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, objects[0].GroupVersionKind())
+	assert.Equal(t, "my-pod", objects[0].GetName())
+
+	t.Run("custom types still require a scheme", func(t *testing.T) {
+		_, err := k8s.ToUnstructured(nil, &Custom{ObjectMeta: metav1.ObjectMeta{Name: "custom"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestToUnstructuredCopiesUnstructuredInputs(t *testing.T) {
+	input := &unstructured.Unstructured{}
+	input.SetAPIVersion("v1")
+	input.SetKind("ConfigMap")
+	input.SetName("my-cm")
+	input.SetLabels(map[string]string{"app": "my-app"})
+
+	objects, err := k8s.ToUnstructured(nil, input)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	objects[0].SetName("changed")
+	objects[0].SetLabels(map[string]string{"app": "changed"})
+
+	assert.Equal(t, "my-cm", input.GetName())
+	assert.Equal(t, map[string]string{"app": "my-app"}, input.GetLabels())
+}
+
+func TestToUnstructuredExpandsLists(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	t.Run("typed lists", func(t *testing.T) {
+		objects, err := k8s.ToUnstructuredObjects(scheme,
+			&v1.PodList{
+				Items: []v1.Pod{
+					{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "my-namespace"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "my-namespace"}},
+				},
+			},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "my-namespace"}},
+		)
+		require.NoError(t, err)
+		require.Len(t, objects, 3)
+		assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, objects[0].GroupVersionKind())
+		assert.Equal(t, "pod-1", objects[0].GetName())
+		assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, objects[1].GroupVersionKind())
+		assert.Equal(t, "pod-2", objects[1].GetName())
+		assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, objects[2].GroupVersionKind())
+	})
+	t.Run("unstructured lists", func(t *testing.T) {
+		lists, err := k8s.ParseUnstructured(strings.NewReader(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm-1
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: secret-1
+`))
+		require.NoError(t, err)
+		objects, err := k8s.ToUnstructured(scheme, k8s.ToClientObject(lists)...)
+		require.NoError(t, err)
+		require.Len(t, objects, 2)
+		assert.Equal(t, "ConfigMap", objects[0].GetKind())
+		assert.Equal(t, "cm-1", objects[0].GetName())
+		assert.Equal(t, "Secret", objects[1].GetKind())
+		assert.Equal(t, "secret-1", objects[1].GetName())
+	})
+}
+
+func TestParseErrorUnwraps(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	var err error = fmt.Errorf("parsing failed: %w", &k8s.ParseError{Data: []byte("data"), Err: sentinel})
+	assert.ErrorIs(t, err, sentinel)
+
+	var parseErr *k8s.ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, []byte("data"), parseErr.Data)
+}
+
+func TestParseGzippedObjects(t *testing.T) {
+	d := bytes.Buffer{}
+	gz := gzip.NewWriter(&d)
+	_, err := gz.Write([]byte(testObjects))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	o, err := k8s.ParseUnstructured(&d)
+	require.NoError(t, err)
+	require.Len(t, o, 2)
+	assert.Equal(t, schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Namespace"}, o[0].GetObjectKind().GroupVersionKind())
+	assert.Equal(t, schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"}, o[1].GetObjectKind().GroupVersionKind())
+}
+
+type CustomV2Spec struct {
+	Size int `json:"size"`
+}
+
+type CustomV2 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              CustomV2Spec `json:"spec"`
+}
+
+func (c *CustomV2) DeepCopyObject() runtime.Object {
+	r := *c
+	c.ObjectMeta.DeepCopyInto(&r.ObjectMeta)
+	return &r
+}
+
+func TestConvertToVersion(t *testing.T) {
+	v1GV := schema.GroupVersion{Group: "custom.testing.ltd", Version: "v1"}
+	v2GV := schema.GroupVersion{Group: "custom.testing.ltd", Version: "v2"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(v1GV, &Custom{})
+	scheme.AddKnownTypeWithName(v2GV.WithKind("Custom"), &CustomV2{})
+
+	custom := &Custom{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-custom"},
+		Spec:       CustomSpec{Replicas: 3},
+	}
+
+	t.Run("without registered conversion an error is returned", func(t *testing.T) {
+		_, err := k8s.ConvertToVersion(scheme, custom, v2GV)
+		assert.ErrorContains(t, err, "unable to convert *k8s_test.Custom to custom.testing.ltd/v2")
+	})
+
+	t.Run("with a registered conversion the object is converted", func(t *testing.T) {
+		require.NoError(t, scheme.AddConversionFunc((*Custom)(nil), (*CustomV2)(nil), func(a, b interface{}, scope conversion.Scope) error {
+			in, out := a.(*Custom), b.(*CustomV2)
+			out.ObjectMeta = in.ObjectMeta
+			out.Spec.Size = in.Spec.Replicas
+			return nil
+		}))
+		o, err := k8s.ConvertToVersion(scheme, custom, v2GV)
+		require.NoError(t, err)
+		assert.Equal(t, &CustomV2{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Custom",
+				APIVersion: "custom.testing.ltd/v2",
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "my-custom"},
+			Spec:       CustomV2Spec{Size: 3},
+		}, o)
+	})
+}
+
+func TestParseSkipsBlankDocuments(t *testing.T) {
+	t.Run("tab indented blank lines", func(t *testing.T) {
+		o, err := k8s.ParseUnstructured(strings.NewReader("---\n\t\n\t\t\n---\n\t# indented comment\n---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: some-name\n"))
+		require.NoError(t, err)
+		require.Len(t, o, 1)
+		assert.Equal(t, "some-name", o[0].GetName())
+	})
+	t.Run("CRLF comment only documents", func(t *testing.T) {
+		o, err := k8s.ParseUnstructured(strings.NewReader("---\r\n# some comment\r\n\r\n---\r\napiVersion: v1\r\nkind: Namespace\r\nmetadata:\r\n  name: some-name\r\n"))
+		require.NoError(t, err)
+		require.Len(t, o, 1)
+		assert.Equal(t, "some-name", o[0].GetName())
+	})
+}
+
+func TestParsePreservesLeadingDashes(t *testing.T) {
+	t.Run("a leading list item is not trimmed", func(t *testing.T) {
+		_, err := k8s.ParseUnstructured(strings.NewReader("---\n- item\n"))
+		var parseErr *k8s.ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, "- item\n", string(parseErr.Data))
+	})
+	t.Run("values made of dashes are preserved", func(t *testing.T) {
+		o, err := k8s.ParseUnstructured(strings.NewReader("---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: my-cm\ndata:\n  separator: ----\n---\n--- \n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: other-cm\n"))
+		require.NoError(t, err)
+		require.Len(t, o, 2)
+		data, _, err := unstructured.NestedStringMap(o[0].Object, "data")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"separator": "----"}, data)
+		assert.Equal(t, "other-cm", o[1].GetName())
+	})
+}
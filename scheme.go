@@ -0,0 +1,29 @@
+package k8s
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// NewDefaultScheme returns a new scheme knowing the client-go built-in types and the apiextensions types,
+// like CustomResourceDefinition.
+func NewDefaultScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+	return scheme
+}
+
+// NewSchemeWith returns a new scheme knowing the types of NewDefaultScheme, plus the ones registered by adders,
+// like the AddToScheme functions of custom API groups.
+func NewSchemeWith(adders ...func(*runtime.Scheme) error) (*runtime.Scheme, error) {
+	scheme := NewDefaultScheme()
+	for _, add := range adders {
+		if err := add(scheme); err != nil {
+			return nil, err
+		}
+	}
+	return scheme, nil
+}
@@ -0,0 +1,39 @@
+package k8s_test
+
+import (
+	"errors"
+	"testing"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNewDefaultScheme(t *testing.T) {
+	scheme := k8s.NewDefaultScheme()
+	assert.True(t, scheme.Recognizes(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}))
+	assert.True(t, scheme.Recognizes(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}))
+	assert.NotSame(t, scheme, k8s.NewDefaultScheme())
+}
+
+func TestNewSchemeWith(t *testing.T) {
+	customGV := schema.GroupVersion{Group: "example.com", Version: "v1"}
+
+	scheme, err := k8s.NewSchemeWith(func(s *runtime.Scheme) error {
+		s.AddKnownTypeWithName(customGV.WithKind("Custom"), &Custom{})
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, scheme.Recognizes(customGV.WithKind("Custom")))
+	assert.True(t, scheme.Recognizes(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}))
+
+	t.Run("errors are returned", func(t *testing.T) {
+		scheme, err := k8s.NewSchemeWith(func(s *runtime.Scheme) error {
+			return errors.New("registration failed")
+		})
+		assert.EqualError(t, err, "registration failed")
+		assert.Nil(t, scheme)
+	})
+}
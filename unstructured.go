@@ -0,0 +1,377 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+type objectIdentity struct {
+	GroupKind schema.GroupKind
+	Namespace string
+	Name      string
+}
+
+func identityOf(u *unstructured.Unstructured) objectIdentity {
+	return objectIdentity{
+		GroupKind: u.GroupVersionKind().GroupKind(),
+		Namespace: u.GetNamespace(),
+		Name:      u.GetName(),
+	}
+}
+
+// KeyOf returns the key identifying the object in the client calls.
+func KeyOf(u *unstructured.Unstructured) client.ObjectKey {
+	return client.ObjectKey{Namespace: u.GetNamespace(), Name: u.GetName()}
+}
+
+// GVKOf returns the group version kind declared by the object.
+func GVKOf(u *unstructured.Unstructured) schema.GroupVersionKind {
+	return u.GroupVersionKind()
+}
+
+// DeduplicateObjects removes objects defined several times, identified by their group, kind, namespace and name.
+// The last definition of each object wins, and the remaining objects are kept in the input order.
+func DeduplicateObjects(objs []*unstructured.Unstructured) []*unstructured.Unstructured {
+	last := map[objectIdentity]int{}
+	for i, o := range objs {
+		last[identityOf(o)] = i
+	}
+	r := []*unstructured.Unstructured{}
+	for i, o := range objs {
+		if last[identityOf(o)] == i {
+			r = append(r, o)
+		}
+	}
+	return r
+}
+
+// defaultApplyPriority defines the tier in which each kind is applied by SortForApply, lower tiers first.
+// It is never modified, so it can be read concurrently.
+var defaultApplyPriority = map[string]int{
+	"Namespace":                0,
+	"CustomResourceDefinition": 1,
+	"PriorityClass":            2,
+	"ResourceQuota":            2,
+	"LimitRange":               2,
+	"NetworkPolicy":            2,
+	"ServiceAccount":           3,
+	"ConfigMap":                4,
+	"Secret":                   4,
+	"StorageClass":             5,
+	"PersistentVolume":         5,
+	"PersistentVolumeClaim":    6,
+	"ClusterRole":              7,
+	"ClusterRoleBinding":       8,
+	"Role":                     7,
+	"RoleBinding":              8,
+	"Service":                  9,
+	"DaemonSet":                10,
+	"Pod":                      10,
+	"ReplicaSet":               10,
+	"Deployment":               10,
+	"StatefulSet":              10,
+	"Job":                      10,
+	"CronJob":                  10,
+	"HorizontalPodAutoscaler":  11,
+	"PodDisruptionBudget":      11,
+	"Ingress":                  11,
+}
+
+// DefaultApplyPriority returns a copy of the tier in which each kind is applied by SortForApply, lower tiers first.
+// Kinds missing from the table, like custom resources, are applied last.
+func DefaultApplyPriority() map[string]int {
+	priority := make(map[string]int, len(defaultApplyPriority))
+	for kind, p := range defaultApplyPriority {
+		priority[kind] = p
+	}
+	return priority
+}
+
+type sortOptions struct {
+	priority map[string]int
+}
+
+// WithApplyPriority overrides the tier of the listed kinds when sorting with SortForApply.
+// The other kinds keep their DefaultApplyPriority tier.
+func WithApplyPriority(priority map[string]int) func(o *sortOptions) {
+	return func(o *sortOptions) {
+		for kind, p := range priority {
+			o.priority[kind] = p
+		}
+	}
+}
+
+func kindPriority(kind string) int {
+	return priorityOf(defaultApplyPriority, kind)
+}
+
+func priorityOf(priority map[string]int, kind string) int {
+	if p, ok := priority[kind]; ok {
+		return p
+	}
+	return math.MaxInt
+}
+
+// SortForApply sorts objects in place so that dependencies are applied first, following DefaultApplyPriority
+// unless overridden with WithApplyPriority.
+// Objects sharing the same priority keep their relative order.
+func SortForApply(objs []*unstructured.Unstructured, opts ...func(o *sortOptions)) {
+	options := sortOptions{priority: defaultApplyPriority}
+	if len(opts) > 0 {
+		options.priority = DefaultApplyPriority()
+		for _, opt := range opts {
+			opt(&options)
+		}
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return priorityOf(options.priority, objs[i].GetKind()) < priorityOf(options.priority, objs[j].GetKind())
+	})
+}
+
+func matchesGVK(gvk, filter schema.GroupVersionKind) bool {
+	return (filter.Group == "" || filter.Group == gvk.Group) &&
+		(filter.Version == "" || filter.Version == gvk.Version) &&
+		filter.Kind == gvk.Kind
+}
+
+// FilterByGVK returns the objects matching any of the provided group version kinds, preserving their order.
+// An empty group or version matches any group or version.
+func FilterByGVK(objs []*unstructured.Unstructured, gvks ...schema.GroupVersionKind) []*unstructured.Unstructured {
+	r := []*unstructured.Unstructured{}
+	for _, o := range objs {
+		for _, gvk := range gvks {
+			if matchesGVK(o.GroupVersionKind(), gvk) {
+				r = append(r, o)
+				break
+			}
+		}
+	}
+	return r
+}
+
+type commonMetadataOptions struct {
+	overwrite          bool
+	podTemplatesLabels bool
+}
+
+// WithMetadataOverwrite makes ApplyCommonMetadata replace the labels and annotations already defined on the objects.
+func WithMetadataOverwrite() func(o *commonMetadataOptions) {
+	return func(o *commonMetadataOptions) {
+		o.overwrite = true
+	}
+}
+
+// WithPodTemplateLabels makes ApplyCommonMetadata also set the labels on the pod templates of workloads.
+func WithPodTemplateLabels() func(o *commonMetadataOptions) {
+	return func(o *commonMetadataOptions) {
+		o.podTemplatesLabels = true
+	}
+}
+
+var podTemplatePaths = map[schema.GroupKind][]string{
+	{Group: "apps", Kind: "Deployment"}:        {"spec", "template"},
+	{Group: "apps", Kind: "ReplicaSet"}:        {"spec", "template"},
+	{Group: "apps", Kind: "StatefulSet"}:       {"spec", "template"},
+	{Group: "apps", Kind: "DaemonSet"}:         {"spec", "template"},
+	{Group: "", Kind: "ReplicationController"}: {"spec", "template"},
+	{Group: "batch", Kind: "Job"}:              {"spec", "template"},
+	{Group: "batch", Kind: "CronJob"}:          {"spec", "jobTemplate", "spec", "template"},
+}
+
+func mergeMetadata(current, common map[string]string, overwrite bool) map[string]string {
+	if current == nil {
+		current = map[string]string{}
+	}
+	for k, v := range common {
+		if _, ok := current[k]; !ok || overwrite {
+			current[k] = v
+		}
+	}
+	return current
+}
+
+// ApplyCommonMetadata adds the given labels and annotations to all the objects.
+// Labels and annotations already defined on an object are preserved unless WithMetadataOverwrite is provided.
+func ApplyCommonMetadata(objs []*unstructured.Unstructured, labels, annotations map[string]string, opts ...func(o *commonMetadataOptions)) error {
+	options := &commonMetadataOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	for _, o := range objs {
+		if len(labels) > 0 {
+			o.SetLabels(mergeMetadata(o.GetLabels(), labels, options.overwrite))
+		}
+		if len(annotations) > 0 {
+			o.SetAnnotations(mergeMetadata(o.GetAnnotations(), annotations, options.overwrite))
+		}
+		if !options.podTemplatesLabels || len(labels) == 0 {
+			continue
+		}
+		path, ok := podTemplatePaths[o.GroupVersionKind().GroupKind()]
+		if !ok {
+			continue
+		}
+		labelsPath := append(append([]string{}, path...), "metadata", "labels")
+		templateLabels, _, err := unstructured.NestedStringMap(o.Object, labelsPath...)
+		if err != nil {
+			return fmt.Errorf("%s %s/%s: %w", o.GetKind(), o.GetNamespace(), o.GetName(), err)
+		}
+		err = unstructured.SetNestedStringMap(o.Object, mergeMetadata(templateLabels, labels, options.overwrite), labelsPath...)
+		if err != nil {
+			return fmt.Errorf("%s %s/%s: %w", o.GetKind(), o.GetNamespace(), o.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// DefaultSanitizedFields lists the server populated fields removed by Sanitize by default.
+var DefaultSanitizedFields = [][]string{
+	{"status"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "managedFields"},
+}
+
+type sanitizeOptions struct {
+	fields [][]string
+}
+
+// WithSanitizedFields replaces the fields removed by Sanitize.
+// Each field is provided as its path in the object, like []string{"metadata", "generation"}.
+func WithSanitizedFields(fields ...[]string) func(o *sanitizeOptions) {
+	return func(o *sanitizeOptions) {
+		o.fields = fields
+	}
+}
+
+// Sanitize removes, in place, the server populated fields from the objects so they can be applied again.
+func Sanitize(objs []*unstructured.Unstructured, opts ...func(o *sanitizeOptions)) {
+	options := &sanitizeOptions{
+		fields: DefaultSanitizedFields,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	for _, o := range objs {
+		for _, field := range options.fields {
+			unstructured.RemoveNestedField(o.Object, field...)
+		}
+	}
+}
+
+// IsNamespaced tells whether objects of the given group version kind are namespaced, according to mapper.
+func IsNamespaced(mapper meta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// SetDefaultNamespace sets, in place, the namespace of the namespaced objects that do not define any, like
+// `kubectl apply -n` does. Cluster-scoped objects are left untouched.
+// The scope of each kind is resolved with mapper.
+func SetDefaultNamespace(objs []*unstructured.Unstructured, namespace string, mapper meta.RESTMapper) error {
+	for _, o := range objs {
+		if o.GetNamespace() != "" {
+			continue
+		}
+		namespaced, err := IsNamespaced(mapper, o.GroupVersionKind())
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", o.GetKind(), o.GetName(), err)
+		}
+		if namespaced {
+			o.SetNamespace(namespace)
+		}
+	}
+	return nil
+}
+
+// SetControllerReference sets owner as the controller of child, following the controllerutil semantics.
+// It fails when child is cluster-scoped while owner is namespaced, when they live in different namespaces, or
+// when child already has another controller.
+func SetControllerReference(owner, child *unstructured.Unstructured, scheme *runtime.Scheme) error {
+	return controllerutil.SetControllerReference(owner, child, scheme)
+}
+
+// diffIgnoredFields are the fields, populated by the API server, ignored by Diff.
+var diffIgnoredFields = append([][]string{{"metadata", "generation"}, {"metadata", "selfLink"}}, DefaultSanitizedFields...)
+
+// Diff describes the changes needed to turn current into desired, one changed field per line, sorted by path.
+// Added fields are prefixed with +, removed fields with - and modified fields with ~.
+// Server populated fields, like the resourceVersion or the status, are ignored.
+// The returned diff is empty when both objects are equivalent.
+func Diff(current, desired *unstructured.Unstructured) (string, error) {
+	current = current.DeepCopy()
+	desired = desired.DeepCopy()
+	Sanitize([]*unstructured.Unstructured{current, desired}, WithSanitizedFields(diffIgnoredFields...))
+	lines := []string{}
+	err := diffValues("", current.Object, desired.Object, &lines)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		// skip the change marker to sort by path
+		return lines[i][2:] < lines[j][2:]
+	})
+	return strings.Join(lines, "\n"), nil
+}
+
+func diffValues(path string, current, desired interface{}, lines *[]string) error {
+	currentMap, currentIsMap := current.(map[string]interface{})
+	desiredMap, desiredIsMap := desired.(map[string]interface{})
+	if currentIsMap && desiredIsMap {
+		for key, value := range currentMap {
+			if err := diffValues(joinPath(path, key), value, desiredMap[key], lines); err != nil {
+				return err
+			}
+		}
+		for key, value := range desiredMap {
+			if _, ok := currentMap[key]; !ok {
+				if err := diffValues(joinPath(path, key), nil, value, lines); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if reflect.DeepEqual(current, desired) {
+		return nil
+	}
+	desiredJSON, err := json.Marshal(desired)
+	if err != nil {
+		return err
+	}
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	switch {
+	case current == nil:
+		*lines = append(*lines, fmt.Sprintf("+ %s: %s", path, desiredJSON))
+	case desired == nil:
+		*lines = append(*lines, fmt.Sprintf("- %s: %s", path, currentJSON))
+	default:
+		*lines = append(*lines, fmt.Sprintf("~ %s: %s -> %s", path, currentJSON, desiredJSON))
+	}
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
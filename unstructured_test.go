@@ -0,0 +1,332 @@
+package k8s_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func newUnstructured(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func TestKeyOfAndGVKOf(t *testing.T) {
+	pod := newUnstructured("v1", "Pod", "my-namespace", "my-pod")
+	assert.Equal(t, client.ObjectKey{Namespace: "my-namespace", Name: "my-pod"}, k8s.KeyOf(pod))
+	assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, k8s.GVKOf(pod))
+
+	role := newUnstructured("rbac.authorization.k8s.io/v1", "ClusterRole", "", "my-role")
+	assert.Equal(t, client.ObjectKey{Name: "my-role"}, k8s.KeyOf(role))
+	assert.Equal(t, schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, k8s.GVKOf(role))
+}
+
+func TestDeduplicateObjects(t *testing.T) {
+	first := newUnstructured("v1", "ConfigMap", "my-namespace", "my-cm")
+	first.SetLabels(map[string]string{"version": "first"})
+	namespace := newUnstructured("v1", "Namespace", "", "my-namespace")
+	otherNamespace := newUnstructured("v1", "ConfigMap", "other-namespace", "my-cm")
+	secret := newUnstructured("v1", "Secret", "my-namespace", "my-cm")
+	last := newUnstructured("v1", "ConfigMap", "my-namespace", "my-cm")
+	last.SetLabels(map[string]string{"version": "last"})
+
+	assert.Equal(t,
+		[]*unstructured.Unstructured{namespace, otherNamespace, secret, last},
+		k8s.DeduplicateObjects([]*unstructured.Unstructured{first, namespace, otherNamespace, secret, last}),
+	)
+}
+
+func TestSortForApply(t *testing.T) {
+	namespace := newUnstructured("v1", "Namespace", "", "my-namespace")
+	crd := newUnstructured("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "customs.testing.ltd")
+	serviceAccount := newUnstructured("v1", "ServiceAccount", "my-namespace", "my-sa")
+	configMap := newUnstructured("v1", "ConfigMap", "my-namespace", "my-cm")
+	secret := newUnstructured("v1", "Secret", "my-namespace", "my-secret")
+	deployment := newUnstructured("apps/v1", "Deployment", "my-namespace", "my-deployment")
+	custom := newUnstructured("testing.ltd/v1", "Custom", "my-namespace", "my-custom")
+
+	objs := []*unstructured.Unstructured{custom, deployment, secret, serviceAccount, configMap, crd, namespace}
+	k8s.SortForApply(objs)
+	assert.Equal(t, []*unstructured.Unstructured{namespace, crd, serviceAccount, secret, configMap, deployment, custom}, objs)
+
+	t.Run("the priorities can be overridden", func(t *testing.T) {
+		k8s.SortForApply(objs, k8s.WithApplyPriority(map[string]int{"Custom": 0}))
+		assert.Equal(t, []*unstructured.Unstructured{namespace, custom, crd, serviceAccount, secret, configMap, deployment}, objs)
+	})
+
+	t.Run("overrides do not change the default priorities", func(t *testing.T) {
+		k8s.SortForApply(objs)
+		assert.Equal(t, []*unstructured.Unstructured{namespace, crd, serviceAccount, secret, configMap, deployment, custom}, objs)
+		_, ok := k8s.DefaultApplyPriority()["Custom"]
+		assert.False(t, ok)
+	})
+
+	t.Run("unknown kinds are applied after any configured tier", func(t *testing.T) {
+		objs := []*unstructured.Unstructured{custom, deployment, namespace}
+		k8s.SortForApply(objs, k8s.WithApplyPriority(map[string]int{"Deployment": 1000}))
+		assert.Equal(t, []*unstructured.Unstructured{namespace, deployment, custom}, objs)
+	})
+
+	t.Run("the default priorities are returned as a copy", func(t *testing.T) {
+		k8s.DefaultApplyPriority()["Namespace"] = 100
+		assert.Equal(t, 0, k8s.DefaultApplyPriority()["Namespace"])
+	})
+}
+
+func TestFilterByGVK(t *testing.T) {
+	firstDeployment := newUnstructured("apps/v1", "Deployment", "my-namespace", "first")
+	secondDeployment := newUnstructured("apps/v1", "Deployment", "my-namespace", "second")
+	legacyDeployment := newUnstructured("extensions/v1beta1", "Deployment", "my-namespace", "legacy")
+	configMap := newUnstructured("v1", "ConfigMap", "my-namespace", "my-cm")
+	statefulSet := newUnstructured("apps/v1", "StatefulSet", "my-namespace", "my-sts")
+	objs := []*unstructured.Unstructured{firstDeployment, configMap, legacyDeployment, statefulSet, secondDeployment}
+
+	t.Run("exact group version kind", func(t *testing.T) {
+		assert.Equal(t,
+			[]*unstructured.Unstructured{firstDeployment, secondDeployment},
+			k8s.FilterByGVK(objs, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}),
+		)
+	})
+	t.Run("empty group and version are wildcards", func(t *testing.T) {
+		assert.Equal(t,
+			[]*unstructured.Unstructured{firstDeployment, configMap, legacyDeployment, secondDeployment},
+			k8s.FilterByGVK(objs, schema.GroupVersionKind{Kind: "Deployment"}, schema.GroupVersionKind{Kind: "ConfigMap"}),
+		)
+	})
+	t.Run("no match", func(t *testing.T) {
+		assert.Empty(t, k8s.FilterByGVK(objs, schema.GroupVersionKind{Kind: "Secret"}))
+	})
+}
+
+func TestApplyCommonMetadata(t *testing.T) {
+	newObjects := func() (*unstructured.Unstructured, *unstructured.Unstructured) {
+		configMap := newUnstructured("v1", "ConfigMap", "my-namespace", "my-cm")
+		configMap.SetLabels(map[string]string{"managed-by": "someone-else", "app": "my-app"})
+		deployment := newUnstructured("apps/v1", "Deployment", "my-namespace", "my-deployment")
+		require.NoError(t, unstructured.SetNestedStringMap(deployment.Object, map[string]string{"app": "my-app"}, "spec", "template", "metadata", "labels"))
+		return configMap, deployment
+	}
+	labels := map[string]string{"managed-by": "gitops"}
+	annotations := map[string]string{"commit-sha": "abcdef"}
+
+	t.Run("existing keys are preserved by default", func(t *testing.T) {
+		configMap, deployment := newObjects()
+		require.NoError(t, k8s.ApplyCommonMetadata([]*unstructured.Unstructured{configMap, deployment}, labels, annotations))
+		assert.Equal(t, map[string]string{"managed-by": "someone-else", "app": "my-app"}, configMap.GetLabels())
+		assert.Equal(t, map[string]string{"commit-sha": "abcdef"}, configMap.GetAnnotations())
+		assert.Equal(t, map[string]string{"managed-by": "gitops"}, deployment.GetLabels())
+		assert.Equal(t, map[string]string{"commit-sha": "abcdef"}, deployment.GetAnnotations())
+
+		templateLabels, _, err := unstructured.NestedStringMap(deployment.Object, "spec", "template", "metadata", "labels")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"app": "my-app"}, templateLabels)
+	})
+	t.Run("existing keys are overwritten when requested", func(t *testing.T) {
+		configMap, _ := newObjects()
+		require.NoError(t, k8s.ApplyCommonMetadata([]*unstructured.Unstructured{configMap}, labels, annotations, k8s.WithMetadataOverwrite()))
+		assert.Equal(t, map[string]string{"managed-by": "gitops", "app": "my-app"}, configMap.GetLabels())
+	})
+	t.Run("pod templates labels are set when requested", func(t *testing.T) {
+		configMap, deployment := newObjects()
+		cronJob := newUnstructured("batch/v1", "CronJob", "my-namespace", "my-cronjob")
+		require.NoError(t, k8s.ApplyCommonMetadata([]*unstructured.Unstructured{configMap, deployment, cronJob}, labels, annotations, k8s.WithPodTemplateLabels()))
+
+		templateLabels, _, err := unstructured.NestedStringMap(deployment.Object, "spec", "template", "metadata", "labels")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"app": "my-app", "managed-by": "gitops"}, templateLabels)
+
+		templateLabels, _, err = unstructured.NestedStringMap(cronJob.Object, "spec", "jobTemplate", "spec", "template", "metadata", "labels")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"managed-by": "gitops"}, templateLabels)
+
+		_, found, err := unstructured.NestedFieldNoCopy(configMap.Object, "spec")
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
+
+func TestSanitize(t *testing.T) {
+	newExported := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]interface{}{
+					"name":              "my-cm",
+					"namespace":         "my-namespace",
+					"resourceVersion":   "12345",
+					"uid":               "d9607e19-f88f-11e6-a518-42010a800195",
+					"creationTimestamp": "2024-01-01T00:00:00Z",
+					"generation":        int64(2),
+					"managedFields": []interface{}{
+						map[string]interface{}{"manager": "kubectl"},
+					},
+				},
+				"data": map[string]interface{}{
+					"hello": "world",
+				},
+				"status": map[string]interface{}{
+					"phase": "Active",
+				},
+			},
+		}
+	}
+
+	t.Run("server fields are removed by default", func(t *testing.T) {
+		o := newExported()
+		k8s.Sanitize([]*unstructured.Unstructured{o})
+		assert.Equal(t, map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":       "my-cm",
+				"namespace":  "my-namespace",
+				"generation": int64(2),
+			},
+			"data": map[string]interface{}{
+				"hello": "world",
+			},
+		}, o.Object)
+	})
+	t.Run("the removed fields can be configured", func(t *testing.T) {
+		o := newExported()
+		k8s.Sanitize([]*unstructured.Unstructured{o}, k8s.WithSanitizedFields([]string{"metadata", "generation"}, []string{"status"}))
+		assert.NotContains(t, o.Object, "status")
+		assert.Equal(t, "12345", o.GetResourceVersion())
+		assert.Zero(t, o.GetGeneration())
+	})
+}
+
+func TestSetControllerReference(t *testing.T) {
+	scheme := runtime.NewScheme()
+	newOwner := func() *unstructured.Unstructured {
+		owner := newUnstructured("example.com/v1", "App", "my-namespace", "my-app")
+		owner.SetUID(types.UID("owner-uid"))
+		return owner
+	}
+
+	t.Run("the controller reference is set", func(t *testing.T) {
+		child := newUnstructured("v1", "ConfigMap", "my-namespace", "my-cm")
+		require.NoError(t, k8s.SetControllerReference(newOwner(), child, scheme))
+		assert.Equal(t, []metav1.OwnerReference{{
+			APIVersion:         "example.com/v1",
+			Kind:               "App",
+			Name:               "my-app",
+			UID:                types.UID("owner-uid"),
+			Controller:         ptr.To(true),
+			BlockOwnerDeletion: ptr.To(true),
+		}}, child.GetOwnerReferences())
+	})
+
+	t.Run("a cluster-scoped child can't be owned by a namespaced owner", func(t *testing.T) {
+		child := newUnstructured("rbac.authorization.k8s.io/v1", "ClusterRole", "", "my-role")
+		assert.Error(t, k8s.SetControllerReference(newOwner(), child, scheme))
+		assert.Empty(t, child.GetOwnerReferences())
+	})
+
+	t.Run("a child already controlled by another owner is rejected", func(t *testing.T) {
+		child := newUnstructured("v1", "ConfigMap", "my-namespace", "my-cm")
+		other := newUnstructured("example.com/v1", "App", "my-namespace", "other-app")
+		other.SetUID(types.UID("other-uid"))
+		require.NoError(t, k8s.SetControllerReference(other, child, scheme))
+		err := k8s.SetControllerReference(newOwner(), child, scheme)
+		assert.ErrorAs(t, err, new(*controllerutil.AlreadyOwnedError))
+	})
+}
+
+func TestDiff(t *testing.T) {
+	current := newUnstructured("apps/v1", "Deployment", "my-namespace", "my-app")
+	current.SetLabels(map[string]string{"app": "my-app", "version": "v1"})
+	current.SetResourceVersion("42")
+	require.NoError(t, unstructured.SetNestedField(current.Object, int64(1), "spec", "replicas"))
+	require.NoError(t, unstructured.SetNestedField(current.Object, int64(1), "status", "readyReplicas"))
+
+	t.Run("identical objects have no diff", func(t *testing.T) {
+		desired := current.DeepCopy()
+		desired.SetResourceVersion("")
+		unstructured.RemoveNestedField(desired.Object, "status")
+		diff, err := k8s.Diff(current, desired)
+		require.NoError(t, err)
+		assert.Empty(t, diff)
+	})
+
+	t.Run("changes are described by path", func(t *testing.T) {
+		desired := current.DeepCopy()
+		desired.SetLabels(map[string]string{"app": "my-app", "version": "v2", "team": "platform"})
+		unstructured.RemoveNestedField(desired.Object, "spec", "replicas")
+		diff, err := k8s.Diff(current, desired)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Join([]string{
+			`+ metadata.labels.team: "platform"`,
+			`~ metadata.labels.version: "v1" -> "v2"`,
+			`- spec.replicas: 1`,
+		}, "\n"), diff)
+	})
+
+	t.Run("the inputs are not modified", func(t *testing.T) {
+		_, err := k8s.Diff(current, current.DeepCopy())
+		require.NoError(t, err)
+		assert.Equal(t, "42", current.GetResourceVersion())
+	})
+}
+
+func newTestRESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	return mapper
+}
+
+func TestIsNamespaced(t *testing.T) {
+	mapper := newTestRESTMapper()
+
+	namespaced, err := k8s.IsNamespaced(mapper, schema.GroupVersionKind{Version: "v1", Kind: "Pod"})
+	require.NoError(t, err)
+	assert.True(t, namespaced)
+
+	namespaced, err = k8s.IsNamespaced(mapper, schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+	require.NoError(t, err)
+	assert.False(t, namespaced)
+
+	_, err = k8s.IsNamespaced(mapper, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+	assert.True(t, meta.IsNoMatchError(err))
+}
+
+func TestSetDefaultNamespace(t *testing.T) {
+	t.Run("only namespaced objects without namespace are modified", func(t *testing.T) {
+		pod := newUnstructured("v1", "Pod", "", "my-pod")
+		otherPod := newUnstructured("v1", "Pod", "other", "other-pod")
+		namespace := newUnstructured("v1", "Namespace", "", "my-namespace")
+
+		require.NoError(t, k8s.SetDefaultNamespace([]*unstructured.Unstructured{pod, otherPod, namespace}, "default", newTestRESTMapper()))
+		assert.Equal(t, "default", pod.GetNamespace())
+		assert.Equal(t, "other", otherPod.GetNamespace())
+		assert.Equal(t, "", namespace.GetNamespace())
+	})
+
+	t.Run("unknown kinds fail", func(t *testing.T) {
+		widget := newUnstructured("example.com/v1", "Widget", "", "my-widget")
+
+		err := k8s.SetDefaultNamespace([]*unstructured.Unstructured{widget}, "default", newTestRESTMapper())
+		assert.ErrorContains(t, err, "Widget my-widget")
+		assert.True(t, meta.IsNoMatchError(errors.Unwrap(err)))
+		assert.Equal(t, "", widget.GetNamespace())
+	})
+}
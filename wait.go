@@ -0,0 +1,39 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WaitForCondition gets obj every interval until cond returns true or ctx is done.
+// obj is updated with the last retrieved state.
+// Errors returned by the Get calls or by cond don't stop the wait: when ctx is done, the last one is returned
+// along with the context error.
+func WaitForCondition(ctx context.Context, c client.Client, obj client.Object, cond func(client.Object) (bool, error), interval time.Duration) error {
+	key := client.ObjectKeyFromObject(obj)
+	var lastErr error
+	for {
+		err := c.Get(ctx, key, obj)
+		if err == nil {
+			var done bool
+			done, err = cond(obj)
+			if err == nil && done {
+				return nil
+			}
+		}
+		if err != nil {
+			lastErr = err
+		}
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w: %w", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package k8s_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	k8s "github.com/adevinta/go-k8s-toolkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func deploymentAvailable(obj client.Object) (bool, error) {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return false, errors.New("not a deployment")
+	}
+	return deployment.Status.AvailableReplicas > 0, nil
+}
+
+func TestWaitForCondition(t *testing.T) {
+	t.Run("when the object becomes ready", func(t *testing.T) {
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+		gets := 0
+		c := fake.NewClientBuilder().WithObjects(deployment).WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				gets++
+				if err := c.Get(ctx, key, obj, opts...); err != nil {
+					return err
+				}
+				if gets >= 3 {
+					obj.(*appsv1.Deployment).Status.AvailableReplicas = 1
+				}
+				return nil
+			},
+		}).Build()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		got := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+		require.NoError(t, k8s.WaitForCondition(ctx, c, got, deploymentAvailable, time.Millisecond))
+		assert.Equal(t, 3, gets)
+		assert.Equal(t, int32(1), got.Status.AvailableReplicas)
+	})
+
+	t.Run("when the context is done first", func(t *testing.T) {
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+		c := fake.NewClientBuilder().WithObjects(deployment).Build()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := k8s.WaitForCondition(ctx, c, deployment, deploymentAvailable, time.Millisecond)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("the last predicate error is returned", func(t *testing.T) {
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+		c := fake.NewClientBuilder().WithObjects(deployment).Build()
+		predicateErr := errors.New("deployment failed")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := k8s.WaitForCondition(ctx, c, deployment, func(client.Object) (bool, error) {
+			return false, predicateErr
+		}, time.Millisecond)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, predicateErr)
+	})
+}